@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
+)
+
+// runBacktraces prints the threads of Puma grouped by what they are doing,
+// with identical backtraces shown once, and returns the exit code
+func runBacktraces(args []string) int {
+	fs := flag.NewFlagSet(os.Args[0]+" backtraces", flag.ExitOnError)
+	connFlags := registerConnectionFlags(fs)
+	optDepth := fs.Int("depth", 5, "Number of top frames shown, and compared when grouping threads")
+	_ = fs.Parse(args)
+
+	logger := log.New(os.Stderr, "[mackerel-plugin-puma] ", log.LstdFlags)
+
+	config, err := connFlags.load()
+	if err == nil {
+		err = config.Validate()
+	}
+	if err == nil && *optDepth < 1 {
+		err = fmt.Errorf("depth: must be at least 1")
+	}
+	if err != nil {
+		logger.Printf("Invalid configuration: %v", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	if len(config.Sockets) == 0 {
+		if err := printBacktraces(ctx, config, *optDepth, logger); err != nil {
+			logger.Printf("Failed to read thread backtraces: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	instances, err := application.ParseInstances(config.Sockets)
+	if err != nil {
+		logger.Printf("Invalid configuration: %v", err)
+		return 1
+	}
+	failed := 0
+	for i, instance := range instances {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("== %s ==\n", instance.Name)
+		if err := printBacktraces(ctx, config.ForInstance(instance), *optDepth, logger); err != nil {
+			logger.Printf("instance %s: %v", instance.Name, err)
+			failed++
+		}
+	}
+	if failed == len(instances) {
+		return 1
+	}
+	return 0
+}
+
+// printBacktraces fetches and prints the backtrace summary of one instance
+func printBacktraces(ctx context.Context, config *application.Config, depth int, logger *log.Logger) error {
+	backtraces, err := application.NewMetricsCollector(config, logger).ThreadBacktraces(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Print(presentation.FormatBacktraceSummary(application.SummarizeBacktraces(backtraces, depth)))
+	return nil
+}
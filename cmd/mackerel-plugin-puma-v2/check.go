@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
+)
+
+// runCheck runs the check plugin mode and returns the exit code
+func runCheck(args []string) int {
+	defaults := application.DefaultCheckThresholds()
+
+	fs := flag.NewFlagSet(os.Args[0]+" check", flag.ExitOnError)
+	connFlags := registerConnectionFlags(fs)
+	optBacklogWarning := fs.Float64("backlog-warning", defaults.BacklogWarning, "Warning if backlog exceeds this value")
+	optBacklogCritical := fs.Float64("backlog-critical", defaults.BacklogCritical, "Critical if backlog exceeds this value")
+	optUtilizationWarning := fs.Float64("utilization-warning", defaults.UtilizationWarning, "Warning if thread utilization (%) exceeds this value")
+	optUtilizationCritical := fs.Float64("utilization-critical", defaults.UtilizationCritical, "Critical if thread utilization (%) exceeds this value")
+	optVerbose := fs.Bool("verbose", false, "Log collection details to stderr")
+	_ = fs.Parse(args)
+
+	// Check plugins report through stdout and the exit code only
+	logOutput := io.Discard
+	if *optVerbose {
+		logOutput = os.Stderr
+	}
+	logger := log.New(logOutput, "[mackerel-plugin-puma] ", log.LstdFlags)
+
+	config, err := connFlags.load()
+	if err == nil {
+		// A check should answer quickly rather than retry or sample
+		config.RetryCount = 0
+		config.SampleCount, config.SampleWindow = 1, 0
+		// Committing the metrics runs' state would use up their restart and
+		// CPU deltas
+		config.RunStateFile = ""
+		err = config.Validate()
+	}
+	if err == nil && len(config.Sockets) > 0 {
+		err = fmt.Errorf("check monitors a single instance; run one check per socket")
+	}
+	if err != nil {
+		fmt.Println(presentation.FormatCheckResult(domain.CheckResult{
+			Status:   domain.CheckUnknown,
+			Problems: []string{fmt.Sprintf("invalid configuration: %v", err)},
+		}))
+		return int(domain.CheckUnknown)
+	}
+
+	checker := application.NewHealthChecker(application.NewMetricsCollector(config, logger), application.CheckThresholds{
+		BacklogWarning:      *optBacklogWarning,
+		BacklogCritical:     *optBacklogCritical,
+		UtilizationWarning:  *optUtilizationWarning,
+		UtilizationCritical: *optUtilizationCritical,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result := checker.Check(ctx)
+	fmt.Println(presentation.FormatCheckResult(result))
+	return int(result.Status)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+func TestRunCheck_LeavesRunStateAlone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"backlog":0,"running":5,"pool_capacity":5,"max_threads":5}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "run.state")
+	configFile := filepath.Join(dir, "puma.toml")
+	u, _ := url.Parse(server.URL)
+	config := fmt.Sprintf("host = %q\nport = %q\nrun_state_file = %q\n", u.Hostname(), u.Port(), stateFile)
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := runCheck([]string{"-config", configFile}); status != int(domain.CheckOK) {
+		t.Fatalf("runCheck() = %d, want OK", status)
+	}
+
+	// The metrics mode's restart and CPU deltas are left for its next run
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("check wrote the run state file: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
+)
+
+// prometheusExporter serves collected metrics on /metrics
+type prometheusExporter struct {
+	// mu serializes scrapes; collectors keep per-run state
+	mu        sync.Mutex
+	collector application.Collector
+	formatter *presentation.PrometheusFormatter
+	timeout   time.Duration
+	logger    *log.Logger
+}
+
+// ServeHTTP collects metrics and writes them in the text exposition format
+func (e *prometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), e.timeout)
+	defer cancel()
+
+	up := 1.0
+	collection, err := e.collector.Collect(ctx)
+	if err != nil {
+		e.logger.Printf("Scrape failed: %v", err)
+		collection = domain.NewMetricCollection()
+		up = 0
+	}
+	_ = collection.Add(domain.Metric{
+		Name:      "up",
+		Value:     up,
+		Type:      domain.MetricTypeGauge,
+		Timestamp: time.Now(),
+	})
+
+	w.Header().Set("Content-Type", presentation.ContentType)
+	if err := e.formatter.Write(w, collection); err != nil {
+		e.logger.Printf("Writing response failed: %v", err)
+	}
+}
+
+// runExporter runs a long-lived Prometheus exporter
+func runExporter(args []string) {
+	fs := flag.NewFlagSet(os.Args[0]+" exporter", flag.ExitOnError)
+	connFlags := registerConnectionFlags(fs)
+	optListen := fs.String("listen", ":9394", "Address to serve /metrics on")
+	optPrefix := fs.String("metric-key-prefix", "puma", "Metric name prefix")
+	optExtended := fs.Bool("extended", false, "Collect extended metrics (memory, GC, etc)")
+	optPerWorker := fs.Bool("per-worker", false, "Collect per-worker thread metrics (labelled by worker)")
+	optThreadBacktraces := fs.Bool("thread-backtraces", false, "Count threads by activity from /thread-backtraces (single mode only)")
+	rawFlags := registerRawFlags(fs)
+	_ = fs.Parse(args)
+
+	logger := log.New(os.Stderr, "[mackerel-plugin-puma] ", log.LstdFlags)
+
+	config, err := connFlags.load()
+	if err == nil {
+		if connFlags.isSet("metric-key-prefix") {
+			config.MetricPrefix = *optPrefix
+		}
+		if connFlags.isSet("extended") {
+			config.Extended = *optExtended
+		}
+		if connFlags.isSet("per-worker") {
+			config.PerWorker = *optPerWorker
+		}
+		if connFlags.isSet("thread-backtraces") {
+			config.ThreadBacktraces = *optThreadBacktraces
+		}
+		rawFlags.apply(connFlags, config)
+		// The exporter keeps its run state in memory; a run_state_file is
+		// the metrics mode's and sharing it would use up its deltas
+		config.RunStateFile = ""
+		err = config.Validate()
+	}
+	if err != nil {
+		logger.Fatalf("Invalid configuration: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &prometheusExporter{
+		collector: newCollector(config, logger),
+		formatter: presentation.NewPrometheusFormatter(config.MetricPrefix),
+		timeout:   fetchTimeout + config.SampleWindow,
+		logger:    logger,
+	})
+
+	server := &http.Server{
+		Addr:              *optListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("Serving Prometheus metrics on %s/metrics", *optListen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatalf("Exporter failed: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
+)
+
+func TestPrometheusExporter_SampledPuma7(t *testing.T) {
+	stats, err := os.ReadFile("../../internal/infrastructure/parsers/testdata/puma7_cluster.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(stats)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.PerWorker = true
+	config.SampleCount = 3
+	config.SampleWindow = 20 * time.Millisecond
+
+	logger := log.New(io.Discard, "", 0)
+	exporter := &prometheusExporter{
+		collector: newCollector(config, logger),
+		formatter: presentation.NewPrometheusFormatter(config.MetricPrefix),
+		timeout:   5 * time.Second,
+		logger:    logger,
+	}
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+
+	// Prometheus rejects a scrape with a family declared twice
+	families := make(map[string]bool)
+	for _, line := range strings.Split(body, "\n") {
+		name, ok := strings.CutPrefix(line, "# TYPE ")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(name, " ")
+		if families[name] {
+			t.Errorf("metric family %s declared more than once", name)
+		}
+		families[name] = true
+	}
+
+	for _, name := range []string{"puma_up", "puma_peak_backlog", "puma_sampled_backlog_max", "puma_sampled_running_max"} {
+		if !families[name] {
+			t.Errorf("metric family %s missing from scrape:\n%s", name, body)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
+)
+
+// connectionFlags are the flags shared by every mode for reaching Puma
+type connectionFlags struct {
+	fs             *flag.FlagSet
+	configFile     *string
+	socket         *stringList
+	scheme         *string
+	host           *string
+	port           *string
+	stateFile      *string
+	pidFile        *string
+	token          *string
+	staleThreshold *time.Duration
+	singleMode     *bool
+}
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// registerConnectionFlags registers the shared flags on fs
+func registerConnectionFlags(fs *flag.FlagSet) *connectionFlags {
+	socket := &stringList{}
+	fs.Var(socket, "socket", "Path to Puma control socket; repeat it, use a glob or name=path to monitor several instances")
+
+	return &connectionFlags{
+		fs:             fs,
+		configFile:     fs.String("config", "", "Path to a TOML or YAML config file; flags and environment variables override it"),
+		socket:         socket,
+		scheme:         fs.String("scheme", "http", "Scheme of TCP control server (http or https)"),
+		host:           fs.String("host", "", "Hostname of TCP control server (used when -socket is not set)"),
+		port:           fs.String("port", "9293", "Port of TCP control server"),
+		stateFile:      fs.String("state-file", "", "Path to Puma state file (state_path) to read control URL and token from"),
+		pidFile:        fs.String("pid-file", "", "Path to Puma pidfile, used to find the master process for -extended"),
+		token:          fs.String("token", "", "Control server auth token (or PUMA_CONTROL_TOKEN)"),
+		staleThreshold: fs.Duration("stale-threshold", parsers.DefaultStaleThreshold, "Checkin age after which a worker counts as stale (match Puma's worker_timeout)"),
+		singleMode:     fs.Bool("single-mode", false, "Treat Puma as running in single mode instead of detecting the mode from its stats"),
+	}
+}
+
+// isSet reports whether the flag was given on the command line, as opposed
+// to holding its default
+func (f *connectionFlags) isSet(name string) bool {
+	set := false
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// load builds the config from, in increasing precedence, DefaultConfig,
+// the -config file, environment variables and the flags given on the
+// command line. Each source that names an endpoint replaces the endpoint of
+// the sources below it.
+func (f *connectionFlags) load() (*application.Config, error) {
+	config := application.DefaultConfig()
+
+	if *f.configFile != "" {
+		if err := application.LoadConfigFile(*f.configFile, config); err != nil {
+			return nil, err
+		}
+	}
+
+	// Environment variables
+	if envSocket := os.Getenv("PUMA_SOCKET"); envSocket != "" {
+		config.ClearEndpoint()
+		config.SocketPath = envSocket
+	}
+	if envToken := os.Getenv("PUMA_CONTROL_TOKEN"); envToken != "" {
+		config.Token = envToken
+	}
+
+	// Socket takes precedence, then an explicit TCP host
+	switch {
+	case len(*f.socket) > 0:
+		config.ClearEndpoint()
+		if application.IsMultiInstance(*f.socket) {
+			config.Sockets = *f.socket
+		} else {
+			config.SocketPath = (*f.socket)[0]
+		}
+	case *f.host != "":
+		config.ClearEndpoint()
+		config.Host = *f.host
+	case *f.stateFile != "":
+		config.ClearEndpoint()
+		config.StateFile = *f.stateFile
+	}
+	if !config.HasEndpoint() {
+		config.SocketPath = application.DefaultSocketPath
+	}
+
+	if f.isSet("scheme") {
+		config.Scheme = *f.scheme
+	}
+	if f.isSet("port") {
+		config.Port = *f.port
+	}
+	if f.isSet("pid-file") {
+		config.PidFile = *f.pidFile
+	}
+	if f.isSet("token") {
+		config.Token = *f.token
+	}
+	if f.isSet("stale-threshold") {
+		config.StaleThreshold = *f.staleThreshold
+	}
+	if f.isSet("single-mode") {
+		config.SingleMode = *f.singleMode
+	}
+
+	return config, nil
+}
+
+// rawFlags are the flags for passing unknown stats fields through
+type rawFlags struct {
+	enabled *bool
+	allow   *string
+	deny    *string
+}
+
+// registerRawFlags registers the raw passthrough flags on fs
+func registerRawFlags(fs *flag.FlagSet) *rawFlags {
+	return &rawFlags{
+		enabled: fs.Bool("raw", false, "Pass numeric stats fields the plugin doesn't know through as raw.* metrics"),
+		allow:   fs.String("raw-allow", "", "Comma-separated patterns of raw metrics to keep (e.g. stats.*,workers.busy_*)"),
+		deny:    fs.String("raw-deny", "", "Comma-separated patterns of raw metrics to drop; wins over -raw-allow"),
+	}
+}
+
+// apply copies the raw flags given on the command line into config
+func (f *rawFlags) apply(connFlags *connectionFlags, config *application.Config) {
+	if connFlags.isSet("raw") {
+		config.RawMetrics = *f.enabled
+	}
+	if connFlags.isSet("raw-allow") {
+		config.RawAllow = splitList(*f.allow)
+	}
+	if connFlags.isSet("raw-deny") {
+		config.RawDeny = splitList(*f.deny)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,29 +2,34 @@ package main
 
 import (
 	"context"
+	"crypto/sha1"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	mp "github.com/mackerelio/go-mackerel-plugin"
+	"github.com/mackerelio/golib/pluginutil"
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
-	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
 )
 
 // PumaPlugin represents the Puma plugin
 type PumaPlugin struct {
 	Socket    string
-	Prefix    string
-	collector interface{} // Can be MetricsCollector or ExtendedMetricsCollector
+	collector application.Collector
 	formatter *presentation.MackerelPlugin
+	// timeout bounds a whole collection, including the sample window
+	timeout time.Duration
 }
 
 // MetricKeyPrefix returns the metric key prefix
 func (p *PumaPlugin) MetricKeyPrefix() string {
-	return p.Prefix
+	return p.formatter.MetricKeyPrefix()
 }
 
 // GraphDefinition returns graph definitions
@@ -34,21 +39,10 @@ func (p *PumaPlugin) GraphDefinition() map[string]mp.Graphs {
 
 // FetchMetrics fetches metrics from Puma
 func (p *PumaPlugin) FetchMetrics() (map[string]float64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	defer cancel()
 
-	// Check if we're using extended collector
-	var collection *domain.MetricCollection
-	var err error
-	
-	if extCollector, ok := p.collector.(*application.ExtendedMetricsCollector); ok {
-		collection, err = extCollector.CollectWithSystemMetrics(ctx)
-	} else if baseCollector, ok := p.collector.(*application.MetricsCollector); ok {
-		collection, err = baseCollector.Collect(ctx)
-	} else {
-		return nil, fmt.Errorf("unknown collector type")
-	}
-	
+	collection, err := p.collector.Collect(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -56,37 +50,129 @@ func (p *PumaPlugin) FetchMetrics() (map[string]float64, error) {
 	return p.formatter.FormatMetrics(collection), nil
 }
 
+// runStatePath returns where to persist data between runs: next to the
+// -tempfile if given, otherwise in the plugin work directory keyed by the
+// command line like go-mackerel-plugin's own tempfile
+func runStatePath(tempfile, prefix string, args []string) string {
+	if tempfile != "" {
+		return tempfile + ".state"
+	}
+	name := fmt.Sprintf("mackerel-plugin-%s-%x.state", prefix, sha1.Sum([]byte(strings.Join(args, " "))))
+	return filepath.Join(pluginutil.PluginWorkDir(), name)
+}
+
+// newCollector builds the collector for config: one per instance when
+// several are configured, each wrapped for extended metrics if requested
+func newCollector(config *application.Config, logger *log.Logger) application.Collector {
+	if len(config.Sockets) == 0 {
+		return newInstanceCollector(config, logger)
+	}
+
+	return application.NewMultiCollector(config.Sockets, func(instance application.Instance) application.Collector {
+		return newInstanceCollector(config.ForInstance(instance), logger)
+	}, logger)
+}
+
+// newInstanceCollector builds the collector for a single Puma instance
+func newInstanceCollector(config *application.Config, logger *log.Logger) application.Collector {
+	baseCollector := application.NewMetricsCollector(config, logger)
+	if config.Extended {
+		return application.NewExtendedMetricsCollector(baseCollector)
+	}
+	return baseCollector
+}
+
+// graphSingleMode reports whether to leave the worker graphs out of the
+// graph definitions: when single mode is configured, or when mackerel-agent
+// asks for the definitions (MACKEREL_AGENT_PLUGIN_META) and the one
+// monitored instance runs in single mode
+func graphSingleMode(config *application.Config, logger *log.Logger) bool {
+	if config.SingleMode {
+		return true
+	}
+	if os.Getenv("MACKEREL_AGENT_PLUGIN_META") == "" || len(config.Sockets) > 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+	metadata, err := application.NewMetricsCollector(config, logger).Metadata(ctx)
+	if err != nil {
+		logger.Printf("Failed to detect the Puma mode, defining all graphs: %v", err)
+		return false
+	}
+	return metadata.Mode == string(infrastructure.ModeSingle)
+}
+
+// fetchTimeout bounds a collection without sampling; mackerel-agent kills
+// plugins after 30 seconds unless timeout_seconds is raised
+const fetchTimeout = 30 * time.Second
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "metadata":
+			os.Exit(runMetadata(os.Args[2:]))
+		case "backtraces":
+			os.Exit(runBacktraces(os.Args[2:]))
+		case "exporter":
+			runExporter(os.Args[2:])
+			return
+		}
+	}
+	runMetrics(os.Args[1:])
+}
+
+// runMetrics runs the Mackerel metrics plugin
+func runMetrics(args []string) {
 	// Command line options
-	optSocket := flag.String("socket", "", "Path to Puma control socket")
-	// optScheme := flag.String("scheme", "http", "Scheme (not used with socket)")
-	// optHost := flag.String("host", "localhost", "Hostname (not used with socket)")
-	// optPort := flag.String("port", "9293", "Port (not used with socket)")
-	optPrefix := flag.String("metric-key-prefix", "puma", "Metric key prefix")
-	optTempfile := flag.String("tempfile", "", "Temp file name")
-	optExtended := flag.Bool("extended", false, "Collect extended metrics (memory, GC, etc)")
-	flag.Parse()
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	connFlags := registerConnectionFlags(fs)
+	optPrefix := fs.String("metric-key-prefix", "puma", "Metric key prefix")
+	optLabelPrefix := fs.String("metric-label-prefix", "", "Graph title prefix (default: metric key prefix with the first letter capitalized)")
+	optTempfile := fs.String("tempfile", "", "Temp file name")
+	optExtended := fs.Bool("extended", false, "Collect extended metrics (memory, GC, etc)")
+	optPerWorker := fs.Bool("per-worker", false, "Collect per-worker thread metrics (workers.<index>.*)")
+	optThreadBacktraces := fs.Bool("thread-backtraces", false, "Count threads by activity from /thread-backtraces (thread_activity.*, single mode only)")
+	optSamples := fs.Int("samples", 1, "Number of stats samples per run, for peak and p95 metrics")
+	optSampleWindow := fs.Duration("sample-window", 0, "Duration to spread the samples over (e.g. 20s)")
+	rawFlags := registerRawFlags(fs)
+	_ = fs.Parse(args)
 
 	// Setup logger
 	logger := log.New(os.Stderr, "[mackerel-plugin-puma] ", log.LstdFlags)
 
 	// Create config
-	config := application.DefaultConfig()
-	config.MetricPrefix = *optPrefix
-
-	// Socket takes precedence
-	if *optSocket != "" {
-		config.SocketPath = *optSocket
-	} else {
-		// Check environment variable
-		if envSocket := os.Getenv("PUMA_SOCKET"); envSocket != "" {
-			config.SocketPath = envSocket
-		}
+	config, err := connFlags.load()
+	if err != nil {
+		logger.Fatalf("Invalid configuration: %v", err)
 	}
-
-	// Default to Unix socket
-	if config.SocketPath == "" {
-		config.SocketPath = "/tmp/puma.sock"
+	if connFlags.isSet("metric-key-prefix") {
+		config.MetricPrefix = *optPrefix
+	}
+	if connFlags.isSet("metric-label-prefix") {
+		config.LabelPrefix = *optLabelPrefix
+	}
+	if connFlags.isSet("extended") {
+		config.Extended = *optExtended
+	}
+	if connFlags.isSet("per-worker") {
+		config.PerWorker = *optPerWorker
+	}
+	if connFlags.isSet("thread-backtraces") {
+		config.ThreadBacktraces = *optThreadBacktraces
+	}
+	if connFlags.isSet("samples") {
+		config.SampleCount = *optSamples
+	}
+	if connFlags.isSet("sample-window") {
+		config.SampleWindow = *optSampleWindow
+	}
+	rawFlags.apply(connFlags, config)
+	if config.RunStateFile == "" {
+		config.RunStateFile = runStatePath(*optTempfile, config.MetricPrefix, args)
 	}
 
 	// Validate config
@@ -95,26 +181,27 @@ func main() {
 	}
 
 	// Create plugin
-	baseCollector := application.NewMetricsCollector(config, logger)
-	formatter := presentation.NewMackerelPlugin(config.MetricPrefix)
-
-	var collector interface{}
-	if *optExtended {
+	formatter := presentation.NewMackerelPlugin(config.MetricPrefix, presentation.GraphOptions{
+		PerWorker:     config.PerWorker,
+		LabelPrefix:   config.LabelPrefix,
+		MultiInstance: len(config.Sockets) > 0,
+		Raw:           config.RawMetrics,
+		SingleMode:    graphSingleMode(config, logger),
+	})
+
+	if config.Extended {
 		logger.Println("Using extended metrics collector")
-		collector = application.NewExtendedMetricsCollector(baseCollector)
-	} else {
-		collector = baseCollector
 	}
 
 	plugin := &PumaPlugin{
 		Socket:    config.SocketPath,
-		Prefix:    config.MetricPrefix,
-		collector: collector,
+		collector: newCollector(config, logger),
 		formatter: formatter,
+		timeout:   fetchTimeout + config.SampleWindow,
 	}
 
 	// Run plugin
 	helper := mp.NewMackerelPlugin(plugin)
 	helper.Tempfile = *optTempfile
 	helper.Run()
-}
\ No newline at end of file
+}
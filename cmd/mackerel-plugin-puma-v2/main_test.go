@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	mp "github.com/mackerelio/go-mackerel-plugin"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
+)
+
+func TestPumaPlugin_MultiInstanceSampledOutput(t *testing.T) {
+	stats, err := os.ReadFile("../../internal/infrastructure/parsers/testdata/puma7_cluster.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	var sockets []string
+	for _, name := range []string{"app1", "app2"} {
+		path := filepath.Join(dir, name+".sock")
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(stats)
+		})}
+		go func() { _ = server.Serve(listener) }()
+		defer server.Close()
+		sockets = append(sockets, name+"="+path)
+	}
+
+	config := application.DefaultConfig()
+	config.Sockets = sockets
+	config.SampleCount = 3
+	config.SampleWindow = 20 * time.Millisecond
+
+	logger := log.New(io.Discard, "", 0)
+	plugin := &PumaPlugin{
+		collector: newCollector(config, logger),
+		formatter: presentation.NewMackerelPlugin(config.MetricPrefix, presentation.GraphOptions{MultiInstance: true}),
+		timeout:   5 * time.Second,
+	}
+	lines := outputLines(t, plugin)
+
+	// A wildcard metric whose name is a prefix of another's prints that
+	// one again under its own graph
+	seen := make(map[string]bool)
+	var backlog []string
+	for _, line := range lines {
+		key, _, _ := strings.Cut(line, "\t")
+		if seen[key] {
+			t.Errorf("%s printed more than once", key)
+		}
+		seen[key] = true
+		if name, ok := strings.CutPrefix(key, "puma.app1.backlog."); ok {
+			backlog = append(backlog, name)
+		}
+	}
+
+	slices.Sort(backlog)
+	want := []string{"backlog", "peak_backlog", "peak_reactor", "sampled.backlog_avg", "sampled.backlog_max"}
+	if !slices.Equal(backlog, want) {
+		t.Errorf("app1 backlog graph metrics = %v, want %v", backlog, want)
+	}
+}
+
+// outputLines returns the lines go-mackerel-plugin prints for plugin,
+// without their timestamps
+func outputLines(t *testing.T, plugin mp.PluginWithPrefix) []string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	helper := mp.NewMackerelPlugin(plugin)
+	helper.Tempfile = filepath.Join(t.TempDir(), "tempfile")
+	helper.OutputValues()
+	_ = w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, value, _ := strings.Cut(line, "\t")
+		value, _, _ = strings.Cut(value, "\t")
+		lines = append(lines, key+"\t"+value)
+	}
+	return lines
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+)
+
+// runMetadata prints the Puma and Ruby versions as JSON for a
+// mackerel-agent metadata plugin ([plugin.metadata.<name>]) and returns the
+// exit code
+func runMetadata(args []string) int {
+	fs := flag.NewFlagSet(os.Args[0]+" metadata", flag.ExitOnError)
+	connFlags := registerConnectionFlags(fs)
+	_ = fs.Parse(args)
+
+	logger := log.New(os.Stderr, "[mackerel-plugin-puma] ", log.LstdFlags)
+
+	config, err := connFlags.load()
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		logger.Printf("Invalid configuration: %v", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	var output any
+	if len(config.Sockets) == 0 {
+		output, err = application.NewMetricsCollector(config, logger).Metadata(ctx)
+	} else {
+		output, err = instancesMetadata(ctx, config, logger)
+	}
+	if err != nil {
+		logger.Printf("Failed to read metadata: %v", err)
+		return 1
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(output); err != nil {
+		logger.Printf("Failed to write metadata: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// instancesMetadata returns {"instances": {<name>: metadata}} for every
+// instance that answered; it fails only when none did
+func instancesMetadata(ctx context.Context, config *application.Config, logger *log.Logger) (any, error) {
+	instances, err := application.ParseInstances(config.Sockets)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]*application.Metadata, len(instances))
+	var errs []error
+	for _, instance := range instances {
+		m, err := application.NewMetricsCollector(config.ForInstance(instance), logger).Metadata(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("instance %s: %w", instance.Name, err))
+			continue
+		}
+		metadata[instance.Name] = m
+	}
+	if len(metadata) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no Puma control sockets matched")
+		}
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		logger.Print(err)
+	}
+
+	return map[string]any{"instances": metadata}, nil
+}
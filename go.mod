@@ -2,9 +2,11 @@ module github.com/srockstyle/mackerel-plugin-puma-v2
 
 go 1.24
 
-require github.com/mackerelio/go-mackerel-plugin v0.1.4
-
 require (
-	github.com/mackerelio/golib v1.2.1 // indirect
-	golang.org/x/text v0.13.0 // indirect
+	github.com/BurntSushi/toml v1.4.0
+	github.com/mackerelio/go-mackerel-plugin v0.1.4
+	github.com/mackerelio/golib v1.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/text v0.13.0 // indirect
@@ -0,0 +1,66 @@
+package application
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
+)
+
+// ThreadBacktraces fetches the backtraces of the threads in the Puma
+// process serving the control app: the only process in single mode, the
+// master in clustered mode
+func (c *MetricsCollector) ThreadBacktraces(ctx context.Context) ([]infrastructure.ThreadBacktrace, error) {
+	if err := c.refreshState(); err != nil {
+		return nil, err
+	}
+
+	backtraces, err := c.client.GetThreadBacktraces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread backtraces: %w", err)
+	}
+	return backtraces, nil
+}
+
+// SummarizeBacktraces groups backtraces by category and their top depth
+// frames
+func SummarizeBacktraces(backtraces []infrastructure.ThreadBacktrace, depth int) *domain.BacktraceSummary {
+	summary := &domain.BacktraceSummary{
+		Threads: len(backtraces),
+		Counts:  make(map[domain.ThreadCategory]int),
+	}
+
+	groups := make(map[string]*domain.BacktraceGroup)
+	var keys []string
+	for _, thread := range backtraces {
+		category := parsers.ClassifyThread(thread)
+		summary.Counts[category]++
+
+		frames := thread.Backtrace[:min(depth, len(thread.Backtrace))]
+		key := string(category) + "\n" + strings.Join(frames, "\n")
+		group, ok := groups[key]
+		if !ok {
+			group = &domain.BacktraceGroup{Category: category, Frames: frames}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.Threads = append(group.Threads, thread.Name)
+	}
+
+	for _, key := range keys {
+		summary.Groups = append(summary.Groups, *groups[key])
+	}
+	slices.SortStableFunc(summary.Groups, func(a, b domain.BacktraceGroup) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(domain.ThreadCategories, a.Category), slices.Index(domain.ThreadCategories, b.Category)),
+			cmp.Compare(len(b.Threads), len(a.Threads)),
+		)
+	})
+
+	return summary
+}
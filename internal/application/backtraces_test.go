@@ -0,0 +1,58 @@
+package application_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestSummarizeBacktraces(t *testing.T) {
+	idle := []string{
+		"/gems/puma-6.4.2/lib/puma/thread_pool.rb:142:in `sleep'",
+		"/gems/puma-6.4.2/lib/puma/thread_pool.rb:142:in `block in spawn_thread'",
+	}
+	query := func(caller string) []string {
+		return []string{
+			"/gems/activerecord-7.1.3/lib/active_record/connection_adapters/postgresql/database_statements.rb:55:in `exec'",
+			caller,
+			"/gems/puma-6.4.2/lib/puma/thread_pool.rb:155:in `block in spawn_thread'",
+		}
+	}
+	backtraces := []infrastructure.ThreadBacktrace{
+		{Name: "Thread: TID-1 puma threadpool 001", Backtrace: query("/app/app/models/user.rb:10:in `find'")},
+		{Name: "Thread: TID-2 puma threadpool 002", Backtrace: idle},
+		{Name: "Thread: TID-3 puma threadpool 003", Backtrace: idle},
+		{Name: "Thread: TID-4 puma threadpool 004", Backtrace: query("/app/app/models/order.rb:20:in `total'")},
+		{Name: "Thread: TID-5 puma threadpool 005", Backtrace: idle},
+	}
+
+	t.Run("grouped by top frames", func(t *testing.T) {
+		summary := application.SummarizeBacktraces(backtraces, 2)
+
+		if summary.Threads != 5 || summary.Counts[domain.ThreadIdle] != 3 || summary.Counts[domain.ThreadDatabase] != 2 {
+			t.Errorf("summary = %d threads, counts %v", summary.Threads, summary.Counts)
+		}
+
+		// Idle first, then the two distinct queries
+		var got []int
+		for _, group := range summary.Groups {
+			got = append(got, len(group.Threads))
+		}
+		if !slices.Equal(got, []int{3, 1, 1}) {
+			t.Errorf("group sizes = %v, want [3 1 1]", got)
+		}
+		if first := summary.Groups[0]; first.Category != domain.ThreadIdle || len(first.Frames) != 2 {
+			t.Errorf("first group = %+v", first)
+		}
+	})
+
+	t.Run("depth 1 merges the queries", func(t *testing.T) {
+		summary := application.SummarizeBacktraces(backtraces, 1)
+		if len(summary.Groups) != 2 || summary.Groups[1].Category != domain.ThreadDatabase || len(summary.Groups[1].Threads) != 2 {
+			t.Errorf("groups = %+v", summary.Groups)
+		}
+	})
+}
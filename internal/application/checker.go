@@ -0,0 +1,106 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// CheckThresholds holds the limits evaluated by HealthChecker
+type CheckThresholds struct {
+	BacklogWarning      float64
+	BacklogCritical     float64
+	UtilizationWarning  float64
+	UtilizationCritical float64
+}
+
+// DefaultCheckThresholds returns thresholds with sensible defaults
+func DefaultCheckThresholds() CheckThresholds {
+	return CheckThresholds{
+		BacklogWarning:      10,
+		BacklogCritical:     50,
+		UtilizationWarning:  80,
+		UtilizationCritical: 95,
+	}
+}
+
+// HealthChecker evaluates Puma health from collected metrics
+type HealthChecker struct {
+	collector  *MetricsCollector
+	thresholds CheckThresholds
+}
+
+// NewHealthChecker creates a new health checker
+func NewHealthChecker(collector *MetricsCollector, thresholds CheckThresholds) *HealthChecker {
+	return &HealthChecker{
+		collector:  collector,
+		thresholds: thresholds,
+	}
+}
+
+// Check collects metrics once and evaluates them against the thresholds
+func (h *HealthChecker) Check(ctx context.Context) domain.CheckResult {
+	collection, err := h.collector.Collect(ctx)
+	if err != nil {
+		if errors.Is(err, infrastructure.ErrAuthentication) {
+			return domain.CheckResult{Status: domain.CheckUnknown, Problems: []string{err.Error()}}
+		}
+		return domain.CheckResult{
+			Status:   domain.CheckCritical,
+			Problems: []string{fmt.Sprintf("control server unreachable: %v", err)},
+		}
+	}
+
+	return h.Evaluate(collection)
+}
+
+// Evaluate checks a metric collection against the thresholds
+func (h *HealthChecker) Evaluate(collection *domain.MetricCollection) domain.CheckResult {
+	values := make(map[string]float64)
+	for _, metric := range collection.All() {
+		if len(metric.Labels) == 0 {
+			values[metric.Name] = metric.Value
+		}
+	}
+
+	var result domain.CheckResult
+
+	workers, booted := values["workers"], values["booted_workers"]
+	if workers > 0 && booted == 0 {
+		result.Raise(domain.CheckCritical, fmt.Sprintf("no booted workers (0/%.0f)", workers))
+	} else if booted < workers {
+		result.Raise(domain.CheckWarning, fmt.Sprintf("booted_workers %.0f < workers %.0f", booted, workers))
+	}
+
+	if stale := values["workers.stale"]; stale > 0 {
+		result.Raise(domain.CheckCritical, fmt.Sprintf("%.0f stale workers (max checkin age %.0fs)", stale, values["workers.max_checkin_age"]))
+	}
+
+	if backlog, ok := values["backlog"]; ok {
+		h.raiseAbove(&result, "backlog", backlog, h.thresholds.BacklogWarning, h.thresholds.BacklogCritical, "%.0f")
+	}
+
+	if utilization, ok := values["thread_utilization"]; ok {
+		h.raiseAbove(&result, "thread_utilization", utilization, h.thresholds.UtilizationWarning, h.thresholds.UtilizationCritical, "%.1f%%")
+	}
+
+	if mode, ok := values["mode"]; ok && mode == 0 {
+		result.Summary = fmt.Sprintf("single mode, backlog %.0f", values["backlog"])
+	} else {
+		result.Summary = fmt.Sprintf("%.0f/%.0f workers booted, backlog %.0f", booted, workers, values["backlog"])
+	}
+	return result
+}
+
+// raiseAbove raises a problem when value exceeds the warning or critical limit
+func (h *HealthChecker) raiseAbove(result *domain.CheckResult, name string, value, warning, critical float64, format string) {
+	switch {
+	case value > critical:
+		result.Raise(domain.CheckCritical, fmt.Sprintf("%s "+format+" > "+format, name, value, critical))
+	case value > warning:
+		result.Raise(domain.CheckWarning, fmt.Sprintf("%s "+format+" > "+format, name, value, warning))
+	}
+}
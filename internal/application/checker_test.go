@@ -0,0 +1,68 @@
+package application_test
+
+import (
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+func TestHealthChecker_Evaluate(t *testing.T) {
+	checker := application.NewHealthChecker(nil, application.DefaultCheckThresholds())
+
+	tests := []struct {
+		name    string
+		metrics map[string]float64
+		want    domain.CheckStatus
+	}{
+		{
+			name:    "healthy",
+			metrics: map[string]float64{"workers": 4, "booted_workers": 4, "backlog": 0, "thread_utilization": 25},
+			want:    domain.CheckOK,
+		},
+		{
+			name:    "worker still booting",
+			metrics: map[string]float64{"workers": 4, "booted_workers": 3, "backlog": 0},
+			want:    domain.CheckWarning,
+		},
+		{
+			name:    "no booted workers",
+			metrics: map[string]float64{"workers": 4, "booted_workers": 0},
+			want:    domain.CheckCritical,
+		},
+		{
+			name:    "backlog warning",
+			metrics: map[string]float64{"workers": 2, "booted_workers": 2, "backlog": 11},
+			want:    domain.CheckWarning,
+		},
+		{
+			name:    "utilization critical",
+			metrics: map[string]float64{"workers": 2, "booted_workers": 2, "thread_utilization": 99},
+			want:    domain.CheckCritical,
+		},
+		{
+			name:    "single mode",
+			metrics: map[string]float64{"mode": 0, "backlog": 2, "thread_utilization": 40},
+			want:    domain.CheckOK,
+		},
+		{
+			name:    "stale worker",
+			metrics: map[string]float64{"workers": 2, "booted_workers": 2, "workers.stale": 1},
+			want:    domain.CheckCritical,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := domain.NewMetricCollection()
+			for name, value := range tt.metrics {
+				_ = collection.Add(domain.Metric{Name: name, Value: value, Type: domain.MetricTypeGauge})
+			}
+
+			got := checker.Evaluate(collection)
+			if got.Status != tt.want {
+				t.Errorf("Evaluate() status = %v, want %v (problems: %v)", got.Status, tt.want, got.Problems)
+			}
+		})
+	}
+}
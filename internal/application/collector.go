@@ -2,6 +2,7 @@ package application
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
@@ -11,103 +12,170 @@ import (
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
 )
 
+// Collector produces a metric collection from Puma
+type Collector interface {
+	Collect(ctx context.Context) (*domain.MetricCollection, error)
+}
+
 // MetricsCollector collects metrics from Puma
 type MetricsCollector struct {
+	config          *Config
+	state           *infrastructure.PumaState
 	client          infrastructure.PumaClient
 	parserFactory   *parsers.ParserFactory
-	versionDetector *infrastructure.VersionDetector
-	detectedVersion string
-	retryCount      int
-	retryInterval   time.Duration
+	detectedVersion infrastructure.Version
+	lastStats       *infrastructure.PumaStats
+	runState        *runStateTracker
 	logger          *log.Logger
+
+	// versionPID is the master pid when the version was detected
+	versionPID int
+	// backtracesSkipped is set once skipping thread backtraces in clustered
+	// mode was logged
+	backtracesSkipped bool
 }
 
 // NewMetricsCollector creates a new metrics collector
 func NewMetricsCollector(config *Config, logger *log.Logger) *MetricsCollector {
-	client := infrastructure.NewPumaClient(config.SocketPath, config.Timeout)
-	
+	client := newPumaClient(config, logger)
+	parserOptions := parsers.Options{
+		PerWorker:      config.PerWorker,
+		StaleThreshold: config.StaleThreshold,
+		Raw: parsers.RawOptions{
+			Enabled: config.RawMetrics,
+			Allow:   config.RawAllow,
+			Deny:    config.RawDeny,
+		},
+	}
+	if config.SingleMode {
+		parserOptions.Mode = infrastructure.ModeSingle
+	}
+
 	return &MetricsCollector{
-		client:          client,
-		parserFactory:   parsers.NewParserFactory(),
-		versionDetector: infrastructure.NewVersionDetector(client),
-		detectedVersion: "",
-		retryCount:      config.RetryCount,
-		retryInterval:   config.RetryInterval,
-		logger:          logger,
+		config:        config,
+		client:        client,
+		parserFactory: parsers.NewParserFactory(parserOptions),
+		runState:      newRunStateTracker(config.RunStateFile),
+		logger:        logger,
 	}
 }
 
+// newPumaClient builds a client for the connection settings in config. The
+// client owns the retries, so that a collection has a single retry budget.
+func newPumaClient(config *Config, logger *log.Logger) infrastructure.PumaClient {
+	retry := config.RetryPolicy()
+	retry.OnRetry = func(retry int, wait time.Duration, err error) {
+		logger.Printf("Retry attempt %d/%d in %v: %v", retry, config.RetryCount, wait.Round(time.Millisecond), err)
+	}
+
+	return infrastructure.NewPumaClient(infrastructure.ClientConfig{
+		SocketPath: config.SocketPath,
+		BaseURL:    config.GetBaseURL(),
+		Token:      config.Token,
+		Timeout:    config.Timeout,
+		Retry:      retry,
+	})
+}
+
 // Collect collects metrics from Puma
 func (c *MetricsCollector) Collect(ctx context.Context) (*domain.MetricCollection, error) {
-	var lastErr error
-
-	for i := range c.retryCount + 1 {
-		if i > 0 {
-			c.logger.Printf("Retry attempt %d/%d after %v", i, c.retryCount, c.retryInterval)
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(c.retryInterval):
-			}
-		}
+	collection, err := c.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		stats, err := c.collectWithTimeout(ctx)
-		if err == nil {
-			return stats, nil
-		}
+	c.commitRunState()
+	return collection, nil
+}
+
+// commitRunState persists the state of a successful run
+func (c *MetricsCollector) commitRunState() {
+	if err := c.runState.commit(); err != nil {
+		c.logger.Printf("Failed to save run state: %v", err)
+	}
+}
 
-		lastErr = err
-		c.logger.Printf("Collection failed: %v", err)
+// collect collects metrics without committing the run state, so that
+// wrapping collectors can add to it first
+func (c *MetricsCollector) collect(ctx context.Context) (*domain.MetricCollection, error) {
+	if err := c.runState.begin(); err != nil {
+		c.logger.Printf("Ignoring previous run state: %v", err)
 	}
 
-	return nil, fmt.Errorf("failed after %d attempts: %w", c.retryCount+1, lastErr)
+	if err := c.refreshState(); err != nil {
+		return nil, err
+	}
+
+	collection, err := c.collectWithTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.addChurnMetrics(collection)
+	return collection, nil
 }
 
-// collectWithTimeout performs collection with timeout
+// collectWithTimeout performs collection with timeout, allowing for the
+// sample window on top of the request timeout. The transports honor ctx, so
+// an expired timeout also stops the request in flight.
 func (c *MetricsCollector) collectWithTimeout(ctx context.Context) (*domain.MetricCollection, error) {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout+c.config.SampleWindow)
 	defer cancel()
 
-	statsChan := make(chan *domain.MetricCollection, 1)
-	errChan := make(chan error, 1)
+	stats, err := c.fetchAndParse(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("collection timeout: %w", err)
+	}
+	return stats, err
+}
 
-	go func() {
-		stats, err := c.fetchAndParse(ctx)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		statsChan <- stats
-	}()
+// fetchAndParse fetches a snapshot of the control endpoints and parses it,
+// sampling stats over the sample window when configured. Only /stats is
+// required; the metrics of other endpoints are added when they succeeded.
+func (c *MetricsCollector) fetchAndParse(ctx context.Context) (*domain.MetricCollection, error) {
+	snapshot := infrastructure.FetchSnapshot(ctx, c.client, c.endpoints()...)
+	if err := snapshot.Err(infrastructure.EndpointStats); err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
 
-	select {
-	case <-ctx.Done():
-		return nil, fmt.Errorf("collection timeout: %w", ctx.Err())
-	case err := <-errChan:
+	collection, err := c.parseStats(snapshot.Stats)
+	if err != nil {
 		return nil, err
-	case stats := <-statsChan:
-		return stats, nil
 	}
+
+	if c.config.SampleCount > 1 {
+		collection = c.sample(ctx, collection)
+	}
+	c.addGCMetrics(snapshot, collection)
+	c.addBacktraceMetrics(snapshot, collection)
+	return collection, nil
 }
 
-// fetchAndParse fetches stats from Puma and parses them
-func (c *MetricsCollector) fetchAndParse(ctx context.Context) (*domain.MetricCollection, error) {
-	// Detect version if not already detected
-	if c.detectedVersion == "" {
-		version, err := c.versionDetector.DetectVersion(ctx)
-		if err != nil {
-			c.logger.Printf("Failed to detect Puma version, assuming 6.x: %v", err)
-			c.detectedVersion = "6.x"
-		} else {
-			c.detectedVersion = version
-			c.logger.Printf("Detected Puma version: %s", version)
-		}
+// endpoints returns the control endpoints each collection fetches
+func (c *MetricsCollector) endpoints() []infrastructure.Endpoint {
+	endpoints := []infrastructure.Endpoint{infrastructure.EndpointStats}
+	if c.config.WithGC || c.config.Extended {
+		endpoints = append(endpoints, infrastructure.EndpointGCStats)
 	}
+	if c.config.ThreadBacktraces {
+		endpoints = append(endpoints, infrastructure.EndpointThreadBacktraces)
+	}
+	return endpoints
+}
 
+// fetchOnce fetches and parses a single stats sample
+func (c *MetricsCollector) fetchOnce(ctx context.Context) (*domain.MetricCollection, error) {
 	stats, err := c.client.GetStats(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stats: %w", err)
 	}
+	return c.parseStats(stats)
+}
+
+// parseStats parses stats with the parser for the detected version
+func (c *MetricsCollector) parseStats(stats *infrastructure.PumaStats) (*domain.MetricCollection, error) {
+	c.updateVersion(stats)
+	c.lastStats = stats
 
 	// Get appropriate parser for the detected version
 	parser := c.parserFactory.GetParser(c.detectedVersion)
@@ -117,4 +185,162 @@ func (c *MetricsCollector) fetchAndParse(ctx context.Context) (*domain.MetricCol
 	}
 
 	return collection, nil
-}
\ No newline at end of file
+}
+
+// updateVersion detects the Puma version from stats on every fetch. A
+// version guessed from the fields only replaces a newer one once Puma
+// restarts, which a downgrade requires: stats fetched while cluster workers
+// boot lack the worker fields that tell recent releases apart.
+func (c *MetricsCollector) updateVersion(stats *infrastructure.PumaStats) {
+	version, fromVersions := infrastructure.DetectStatsVersion(stats)
+	if !fromVersions && version.Compare(c.detectedVersion) < 0 && !c.restarted(stats) {
+		return
+	}
+	c.versionPID = c.MasterPID()
+	if version == c.detectedVersion {
+		return
+	}
+
+	if fromVersions {
+		c.logger.Printf("Detected Puma version: %s", version)
+	} else {
+		c.logger.Printf("Detected Puma version: %s (guessed from the stats fields)", version)
+	}
+	c.detectedVersion = version
+}
+
+// restarted reports whether Puma restarted since the previous stats: the
+// master pid changed, or the boot time or uptime went back
+func (c *MetricsCollector) restarted(stats *infrastructure.PumaStats) bool {
+	if pid := c.MasterPID(); pid != 0 && pid != c.versionPID {
+		return true
+	}
+
+	last := c.lastStats
+	if last == nil {
+		return false
+	}
+	if stats.StartedAt != last.StartedAt {
+		return true
+	}
+	return stats.Uptime != nil && last.Uptime != nil && *stats.Uptime < *last.Uptime
+}
+
+// DetectedVersion returns the Puma version detected from the most recent
+// stats, or the zero Version before the first successful fetch
+func (c *MetricsCollector) DetectedVersion() infrastructure.Version {
+	return c.detectedVersion
+}
+
+// addGCMetrics adds the metrics of /gc-stats when the snapshot includes it
+func (c *MetricsCollector) addGCMetrics(snapshot *infrastructure.Snapshot, collection *domain.MetricCollection) {
+	if _, requested := snapshot.Results[infrastructure.EndpointGCStats]; !requested {
+		return
+	}
+	if err := snapshot.Err(infrastructure.EndpointGCStats); err != nil {
+		// GC stats might not be available, especially in newer Puma versions
+		c.logger.Printf("GC stats not available: %v", err)
+		return
+	}
+
+	// Convert to JSON bytes for the parser
+	gcData, err := json.Marshal(snapshot.GCStats)
+	if err != nil {
+		c.logger.Printf("Failed to marshal GC stats: %v", err)
+		return
+	}
+
+	// Use the detailed GC parser
+	gcParser := &parsers.GCParser{}
+	gcMetrics, err := gcParser.ParseGCStats(gcData)
+	if err != nil {
+		c.logger.Printf("Failed to parse GC stats: %v", err)
+		return
+	}
+
+	// Add all parsed GC metrics to the collection
+	timestamp := time.Now()
+	for _, metric := range gcMetrics.All() {
+		metric.Timestamp = timestamp
+		_ = collection.Add(metric)
+	}
+}
+
+// addBacktraceMetrics adds the thread counts by activity when the snapshot
+// includes /thread-backtraces. In clustered mode the control app runs in
+// the master, whose threads serve no requests, so they are left out.
+func (c *MetricsCollector) addBacktraceMetrics(snapshot *infrastructure.Snapshot, collection *domain.MetricCollection) {
+	if _, requested := snapshot.Results[infrastructure.EndpointThreadBacktraces]; !requested {
+		return
+	}
+	if !c.config.SingleMode && snapshot.Stats.Mode() == infrastructure.ModeCluster {
+		if !c.backtracesSkipped {
+			c.logger.Printf("Skipping thread backtraces: in clustered mode they only show the master's threads")
+			c.backtracesSkipped = true
+		}
+		return
+	}
+	if err := snapshot.Err(infrastructure.EndpointThreadBacktraces); err != nil {
+		c.logger.Printf("Thread backtraces not available: %v", err)
+		return
+	}
+
+	backtraceParser := &parsers.BacktraceParser{}
+	for _, metric := range backtraceParser.ParseBacktraces(snapshot.ThreadBacktraces).All() {
+		_ = collection.Add(metric)
+	}
+}
+
+// refreshState re-reads the Puma state file, if configured, and reconnects
+// when Puma was restarted (new pid) or the control URL or token changed
+func (c *MetricsCollector) refreshState() error {
+	if c.config.StateFile == "" {
+		return nil
+	}
+
+	state, err := infrastructure.ReadStateFile(c.config.StateFile)
+	if err != nil {
+		return err
+	}
+	if state.Equal(c.state) {
+		return nil
+	}
+
+	if err := c.config.ApplyState(state); err != nil {
+		return fmt.Errorf("applying state file %s: %w", c.config.StateFile, err)
+	}
+	if c.state != nil {
+		c.logger.Printf("Puma state changed (pid %d -> %d), reconnecting", c.state.PID, state.PID)
+	}
+
+	c.state = state
+	if closer, ok := c.client.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+	c.client = newPumaClient(c.config, c.logger)
+	c.detectedVersion = infrastructure.Version{}
+
+	return nil
+}
+
+// LastStats returns the raw stats from the most recent successful collection
+func (c *MetricsCollector) LastStats() *infrastructure.PumaStats {
+	return c.lastStats
+}
+
+// MasterPID returns the Puma master pid from the state file or pid file, or
+// 0 when neither is configured
+func (c *MetricsCollector) MasterPID() int {
+	if c.state != nil && c.state.PID > 0 {
+		return c.state.PID
+	}
+	if c.config.PidFile != "" {
+		pid, err := infrastructure.ReadPIDFile(c.config.PidFile)
+		if err != nil {
+			c.logger.Printf("Failed to read pid file: %v", err)
+			return 0
+		}
+		return pid
+	}
+	return 0
+}
@@ -0,0 +1,163 @@
+package application_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+)
+
+func TestMetricsCollector_CollectRetryBudget(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.RetryCount = 2
+	config.RetryInterval = time.Millisecond
+
+	collector := application.NewMetricsCollector(config, log.New(io.Discard, "", 0))
+	if _, err := collector.Collect(t.Context()); err == nil {
+		t.Fatal("Collect() error = nil, want an error")
+	}
+
+	// The retries are not repeated by the collector on top of the client
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestMetricsCollector_CollectWithoutGCStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gc-stats" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, `{"backlog":3,"running":5,"pool_capacity":2,"max_threads":5}`)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.WithGC = true
+
+	collection, err := application.NewMetricsCollector(config, log.New(io.Discard, "", 0)).Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	// The core metrics don't depend on /gc-stats
+	if got := findValue(collection, "backlog"); got == nil || *got != 3 {
+		t.Errorf("backlog = %v, want 3", got)
+	}
+}
+
+func TestMetricsCollector_CollectWithHangingGCStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gc-stats" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+			return
+		}
+		_, _ = io.WriteString(w, `{"backlog":3,"running":5,"pool_capacity":2,"max_threads":5}`)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.WithGC = true
+
+	start := time.Now()
+	collection, err := application.NewMetricsCollector(config, log.New(io.Discard, "", 0)).Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	// /gc-stats is given up on shortly after /stats, well inside the timeout
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Collect() took %v, want it not to wait for /gc-stats", elapsed)
+	}
+	if got := findValue(collection, "backlog"); got == nil || *got != 3 {
+		t.Errorf("backlog = %v, want 3", got)
+	}
+}
+
+func TestMetricsCollector_CollectThreadBacktraces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/thread-backtraces" {
+			_, _ = io.WriteString(w, `[
+				{"name": "Thread: TID-1 puma threadpool 001", "backtrace": ["/gems/puma-6.4.2/lib/puma/thread_pool.rb:142:in `+"`sleep'"+`"]},
+				{"name": "Thread: TID-2 puma threadpool 002", "backtrace": ["/usr/lib/ruby/3.3.0/net/protocol.rb:229:in `+"`wait_readable'"+`"]}
+			]`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"backlog":0,"running":2,"pool_capacity":1,"max_threads":2}`)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.ThreadBacktraces = true
+
+	collection, err := application.NewMetricsCollector(config, log.New(io.Discard, "", 0)).Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	for name, want := range map[string]float64{"thread_activity.idle": 1, "thread_activity.http": 1, "thread_activity.ruby": 0} {
+		if got := findValue(collection, name); got == nil || *got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMetricsCollector_SkipsThreadBacktracesInClusterMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/thread-backtraces" {
+			_, _ = io.WriteString(w, `[{"name": "Thread: TID-1 puma stat pld", "backtrace": ["/gems/puma-6.4.2/lib/puma/cluster.rb:470:in `+"`wait'"+`"]}]`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"workers":1,"booted_workers":1,"worker_status":[{"pid":200,"index":0,
+			"last_status":{"backlog":0,"running":2,"pool_capacity":1,"max_threads":2}}]}`)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.ThreadBacktraces = true
+
+	var logs strings.Builder
+	collector := application.NewMetricsCollector(config, log.New(&logs, "", 0))
+	for range 2 {
+		collection, err := collector.Collect(t.Context())
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		for _, metric := range collection.All() {
+			if strings.HasPrefix(metric.Name, "thread_activity.") {
+				t.Errorf("%s reported in clustered mode", metric.Name)
+			}
+		}
+	}
+	if got := strings.Count(logs.String(), "Skipping thread backtraces"); got != 1 {
+		t.Errorf("skip logged %d times, want once:\n%s", got, logs.String())
+	}
+}
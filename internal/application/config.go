@@ -2,73 +2,292 @@ package application
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"strings"
 	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
 )
 
-// Config holds application configuration
+// metricPrefixPattern matches prefixes usable as a single Mackerel metric
+// name segment; a dot would shift every graph name and wildcard
+var metricPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Config holds application configuration. The toml/yaml keys are those of
+// the -config file (see LoadConfigFile) and of Validate's problems.
 type Config struct {
 	// Connection settings
-	Host       string
-	Port       string
-	SocketPath string
-	Scheme     string
+	Host       string `toml:"host" yaml:"host"`
+	Port       string `toml:"port" yaml:"port"`
+	SocketPath string `toml:"socket_path" yaml:"socket_path"`
+	Scheme     string `toml:"scheme" yaml:"scheme"`
 
 	// Authentication
-	Token string
+	Token string `toml:"token" yaml:"token"`
+
+	// Sockets, when set, are monitored instead of SocketPath: socket paths,
+	// globs or name=path values (see ParseInstances), each instance with its
+	// metrics namespaced by name
+	Sockets []string `toml:"sockets" yaml:"sockets"`
+
+	// StateFile is Puma's state_path; when set, the control URL and
+	// token are read from it instead of SocketPath/Host/Port/Token
+	StateFile string `toml:"state_file" yaml:"state_file"`
+
+	// PidFile is Puma's pidfile, used to find the master process when no
+	// state file is configured
+	PidFile string `toml:"pid_file" yaml:"pid_file"`
 
 	// Behavior settings
-	SingleMode     bool
-	WithGC         bool
-	MetricPrefix   string
+	// SingleMode treats Puma as running in single mode whatever the stats
+	// look like; otherwise the mode is detected from each payload
+	SingleMode bool `toml:"single_mode" yaml:"single_mode"`
+	// WithGC adds Puma's /gc-stats to the core metrics; Extended implies it
+	WithGC bool `toml:"with_gc" yaml:"with_gc"`
+	// ThreadBacktraces adds thread counts by activity from Puma's
+	// /thread-backtraces
+	ThreadBacktraces bool `toml:"thread_backtraces" yaml:"thread_backtraces"`
+	// Extended adds process, GC and plugin runtime metrics
+	Extended     bool   `toml:"extended" yaml:"extended"`
+	PerWorker    bool   `toml:"per_worker" yaml:"per_worker"`
+	MetricPrefix string `toml:"metric_prefix" yaml:"metric_prefix"`
+	// LabelPrefix starts every graph title; derived from MetricPrefix when empty
+	LabelPrefix string `toml:"label_prefix" yaml:"label_prefix"`
+
+	// RawMetrics passes numeric stats fields the plugin doesn't know through
+	// as raw.* metrics, filtered by the RawAllow and RawDeny patterns
+	RawMetrics bool     `toml:"raw_metrics" yaml:"raw_metrics"`
+	RawAllow   []string `toml:"raw_allow" yaml:"raw_allow"`
+	RawDeny    []string `toml:"raw_deny" yaml:"raw_deny"`
+
+	// StaleThreshold is the checkin age after which a worker counts as stale
+	StaleThreshold time.Duration `toml:"stale_threshold" yaml:"stale_threshold"`
+
+	// ProcRoot is where procfs is mounted, for reading Puma process stats
+	ProcRoot string `toml:"proc_root" yaml:"proc_root"`
+
+	// RunStateFile persists data between plugin runs (e.g. CPU samples);
+	// when empty the data is only kept in memory
+	RunStateFile string `toml:"run_state_file" yaml:"run_state_file"`
 
 	// Performance settings
-	Timeout       time.Duration
-	RetryCount    int
-	RetryInterval time.Duration
+	Timeout time.Duration `toml:"timeout" yaml:"timeout"`
+
+	// Failed /stats requests are retried RetryCount times, waiting
+	// RetryInterval and then twice as long each time up to
+	// RetryMaxInterval. No retry starts after RetryMaxElapsed.
+	RetryCount       int           `toml:"retry_count" yaml:"retry_count"`
+	RetryInterval    time.Duration `toml:"retry_interval" yaml:"retry_interval"`
+	RetryMaxInterval time.Duration `toml:"retry_max_interval" yaml:"retry_max_interval"`
+	RetryMaxElapsed  time.Duration `toml:"retry_max_elapsed" yaml:"retry_max_elapsed"`
+
+	// SampleCount stats samples are taken evenly over SampleWindow in each
+	// collection, adding peak and percentile metrics; 1 disables sampling
+	SampleCount  int           `toml:"sample_count" yaml:"sample_count"`
+	SampleWindow time.Duration `toml:"sample_window" yaml:"sample_window"`
+
+	// userToken is Token as set by flag, environment or config file before
+	// a state file was first applied
+	userToken    string
+	stateApplied bool
 }
 
-// DefaultConfig returns a config with sensible defaults
+// DefaultConfig returns a config with sensible defaults. No endpoint is
+// set; callers fall back to DefaultSocketPath when no source names one.
 func DefaultConfig() *Config {
 	return &Config{
-		Host:          "127.0.0.1",
-		Port:          "9293",
-		Scheme:        "http",
-		MetricPrefix:  "puma",
-		Timeout:       10 * time.Second,
-		RetryCount:    3,
-		RetryInterval: 1 * time.Second,
+		Port:             "9293",
+		Scheme:           "http",
+		MetricPrefix:     "puma",
+		StaleThreshold:   parsers.DefaultStaleThreshold,
+		ProcRoot:         "/proc",
+		Timeout:          10 * time.Second,
+		RetryCount:       3,
+		RetryInterval:    1 * time.Second,
+		RetryMaxInterval: 4 * time.Second,
+		RetryMaxElapsed:  8 * time.Second,
+		SampleCount:      1,
+	}
+}
+
+// retryJitter spreads retries by ±20% so that plugins on hosts restarted
+// together don't hit Puma in lockstep
+const retryJitter = 0.2
+
+// RetryPolicy returns the policy for retrying failed /stats requests
+func (c *Config) RetryPolicy() infrastructure.RetryPolicy {
+	return infrastructure.RetryPolicy{
+		MaxRetries:      c.RetryCount,
+		InitialInterval: c.RetryInterval,
+		MaxInterval:     c.RetryMaxInterval,
+		Multiplier:      2,
+		Jitter:          retryJitter,
+		MaxElapsed:      c.RetryMaxElapsed,
 	}
 }
 
-// Validate checks if the configuration is valid
+// maxSampleWindow keeps sampling well inside Mackerel's one-minute interval
+const maxSampleWindow = 50 * time.Second
+
+// DefaultSocketPath is the control socket used when no endpoint is configured
+const DefaultSocketPath = "/tmp/puma.sock"
+
+// HasEndpoint reports whether any way of reaching Puma is configured
+func (c *Config) HasEndpoint() bool {
+	return c.SocketPath != "" || len(c.Sockets) > 0 || c.StateFile != "" || c.Host != ""
+}
+
+// ClearEndpoint removes all endpoint settings, so that a higher-precedence
+// source can replace rather than mix with them
+func (c *Config) ClearEndpoint() {
+	c.SocketPath = ""
+	c.Sockets = nil
+	c.StateFile = ""
+	c.Host = ""
+}
+
+// ValidationError lists every problem found in a config as "key: problem"
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// Validate checks if the configuration is valid, reporting all problems at
+// once as a *ValidationError
 func (c *Config) Validate() error {
-	if c.SocketPath == "" {
-		if c.Host == "" {
-			return fmt.Errorf("either socket path or host must be specified")
-		}
+	var problems []string
+	problem := func(key, format string, args ...any) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if !c.HasEndpoint() {
+		problem("host", "one of socket_path, sockets, state_file or host must be set")
+	}
+	if c.SocketPath == "" && len(c.Sockets) == 0 && c.StateFile == "" && c.Host != "" {
 		if c.Port == "" {
-			return fmt.Errorf("port must be specified when using host")
+			problem("port", "must be set when using host")
 		}
 		if c.Scheme != "http" && c.Scheme != "https" {
-			return fmt.Errorf("scheme must be http or https, got %s", c.Scheme)
+			problem("scheme", "must be http or https, got %q", c.Scheme)
+		}
+	}
+
+	if len(c.Sockets) > 0 {
+		if c.StateFile != "" {
+			problem("sockets", "cannot be combined with state_file")
+		}
+		if c.PidFile != "" {
+			problem("sockets", "cannot be combined with pid_file")
+		}
+		if _, err := ParseInstances(c.Sockets); err != nil {
+			problem("sockets", "%v", err)
+		}
+	}
+
+	if !metricPrefixPattern.MatchString(c.MetricPrefix) {
+		problem("metric_prefix", "must consist of letters, digits, '-' and '_', got %q", c.MetricPrefix)
+	}
+
+	for _, pattern := range c.RawAllow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problem("raw_allow", "invalid pattern %q", pattern)
+		}
+	}
+	for _, pattern := range c.RawDeny {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problem("raw_deny", "invalid pattern %q", pattern)
 		}
 	}
 
 	if c.Timeout <= 0 {
-		return fmt.Errorf("timeout must be positive")
+		problem("timeout", "must be positive")
+	}
+
+	if c.StaleThreshold <= 0 {
+		problem("stale_threshold", "must be positive")
 	}
 
 	if c.RetryCount < 0 {
-		return fmt.Errorf("retry count must be non-negative")
+		problem("retry_count", "must be non-negative")
+	}
+
+	if c.RetryInterval < 0 {
+		problem("retry_interval", "must be non-negative")
+	}
+
+	if c.RetryMaxInterval < 0 {
+		problem("retry_max_interval", "must be non-negative")
+	}
+
+	if c.RetryMaxElapsed < 0 {
+		problem("retry_max_elapsed", "must be non-negative")
+	}
+
+	if c.SampleCount < 1 {
+		problem("sample_count", "must be at least 1")
+	}
+
+	switch {
+	case c.SampleWindow < 0 || c.SampleWindow > maxSampleWindow:
+		problem("sample_window", "must be between 0 and %v", maxSampleWindow)
+	case c.SampleCount > 1 && c.SampleWindow == 0:
+		problem("sample_window", "must be positive when sample_count is above 1")
 	}
 
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
 	return nil
 }
 
+// ApplyState overrides connection settings with those from a Puma state file
+func (c *Config) ApplyState(state *infrastructure.PumaState) error {
+	endpoint, err := state.ControlEndpoint()
+	if err != nil {
+		return err
+	}
+
+	c.SocketPath = endpoint.SocketPath
+	if endpoint.SocketPath == "" {
+		c.Scheme = endpoint.Scheme
+		c.Host = endpoint.Host
+		c.Port = endpoint.Port
+	}
+	// The state file's token wins. A state file without one falls back to
+	// the user's token rather than keeping one from an earlier state file.
+	if !c.stateApplied {
+		c.userToken = c.Token
+		c.stateApplied = true
+	}
+	c.Token = c.userToken
+	if state.ControlAuthToken != "" {
+		c.Token = state.ControlAuthToken
+	}
+
+	return nil
+}
+
+// ForInstance returns a copy of the config that connects to a single
+// instance, keeping its run state apart from the other instances
+func (c *Config) ForInstance(instance Instance) *Config {
+	config := *c
+	config.Sockets = nil
+	config.SocketPath = instance.SocketPath
+	if config.RunStateFile != "" {
+		config.RunStateFile += "." + instance.Name
+	}
+	return &config
+}
+
 // GetBaseURL returns the base URL for API requests
 func (c *Config) GetBaseURL() string {
 	if c.SocketPath != "" {
 		return "http://localhost"
 	}
 	return fmt.Sprintf("%s://%s:%s", c.Scheme, c.Host, c.Port)
-}
\ No newline at end of file
+}
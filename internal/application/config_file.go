@@ -0,0 +1,64 @@
+package application
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile reads a TOML (.toml) or YAML (.yaml, .yml) config file
+// into config. Keys missing from the file keep their current values, so
+// load onto DefaultConfig and apply higher-precedence sources afterwards.
+// Unknown keys are rejected to catch typos.
+func LoadConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = decodeTOML(data, config)
+	case ".yaml", ".yml":
+		err = decodeYAML(data, config)
+	default:
+		return fmt.Errorf("config file %s: unsupported extension %q (use .toml, .yaml or .yml)", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func decodeTOML(data []byte, config *Config) error {
+	meta, err := toml.Decode(string(data), config)
+	if err != nil {
+		return err
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		slices.Sort(keys)
+		return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+func decodeYAML(data []byte, config *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package application_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	config := application.DefaultConfig()
+	config.Host = "127.0.0.1"
+	config.Port = ""
+	config.Scheme = "ftp"
+	config.MetricPrefix = "my.app"
+	config.Timeout = 0
+	config.RetryCount = -1
+	config.RawDeny = []string{"workers.[", "stats.*"}
+
+	err := config.Validate()
+	var validationErr *application.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+
+	want := []string{"port", "scheme", "metric_prefix", "raw_deny", "timeout", "retry_count"}
+	var got []string
+	for _, problem := range validationErr.Problems {
+		key, _, _ := strings.Cut(problem, ": ")
+		got = append(got, key)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Validate() problems = %q, want keys %v", validationErr.Problems, want)
+	}
+
+	if err := application.DefaultConfig().Validate(); err == nil {
+		t.Error("Validate() should require an endpoint")
+	}
+
+	// A pidfile names one master, which the instances would all share
+	config = application.DefaultConfig()
+	config.Sockets = []string{"app1=/tmp/app1.sock", "app2=/tmp/app2.sock"}
+	config.PidFile = "/tmp/puma.pid"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "pid_file") {
+		t.Errorf("Validate() error = %v, want sockets with pid_file rejected", err)
+	}
+}
+
+func TestConfig_ApplyStateToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		userToken   string
+		stateTokens []string
+		want        []string
+	}{
+		{name: "token removed from state", stateTokens: []string{"boot1", ""}, want: []string{"boot1", ""}},
+		{name: "token rotated", stateTokens: []string{"boot1", "boot2"}, want: []string{"boot1", "boot2"}},
+		{name: "user token without state token", userToken: "secret", stateTokens: []string{"boot1", ""}, want: []string{"boot1", "secret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := application.DefaultConfig()
+			config.Token = tt.userToken
+			for i, token := range tt.stateTokens {
+				state := &infrastructure.PumaState{PID: i + 1, ControlURL: "unix:///tmp/puma.sock", ControlAuthToken: token}
+				if err := config.ApplyState(state); err != nil {
+					t.Fatalf("ApplyState() error = %v", err)
+				}
+				if config.Token != tt.want[i] {
+					t.Errorf("Token after state %d = %q, want %q", i+1, config.Token, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"puma.toml": `
+sockets = ["app1=/var/run/app1.sock", "app2=/var/run/app2.sock"]
+token = "secret"
+single_mode = true
+with_gc = true
+retry_count = 0
+retry_interval = "250ms"
+timeout = "5s"
+`,
+		"puma.yaml": `
+sockets:
+  - app1=/var/run/app1.sock
+  - app2=/var/run/app2.sock
+token: secret
+single_mode: true
+with_gc: true
+retry_count: 0
+retry_interval: 250ms
+timeout: 5s
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			config := application.DefaultConfig()
+			if err := application.LoadConfigFile(path, config); err != nil {
+				t.Fatalf("LoadConfigFile() error = %v", err)
+			}
+
+			if len(config.Sockets) != 2 || config.Token != "secret" || !config.SingleMode || !config.WithGC {
+				t.Errorf("LoadConfigFile() = %+v", config)
+			}
+			if config.RetryCount != 0 || config.RetryInterval != 250*time.Millisecond || config.Timeout != 5*time.Second {
+				t.Errorf("retry/timeout = %d, %v, %v", config.RetryCount, config.RetryInterval, config.Timeout)
+			}
+			// Keys missing from the file keep their defaults
+			if config.MetricPrefix != "puma" || config.Port != "9293" {
+				t.Errorf("defaults overwritten: prefix %q, port %q", config.MetricPrefix, config.Port)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown.toml": `socket = "/tmp/puma.sock"`,
+		"unknown.yaml": `socket: /tmp/puma.sock`,
+		"config.json":  `{}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := application.LoadConfigFile(path, application.DefaultConfig()); err == nil {
+				t.Error("LoadConfigFile() should fail")
+			}
+		})
+	}
+}
@@ -2,47 +2,236 @@ package application
 
 import (
 	"context"
-	"encoding/json"
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
-	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
 )
 
 // ExtendedMetricsCollector collects extended metrics including system stats
 type ExtendedMetricsCollector struct {
 	baseCollector *MetricsCollector
+	procReader    *infrastructure.ProcReader
 }
 
 // NewExtendedMetricsCollector creates a new extended metrics collector
 func NewExtendedMetricsCollector(base *MetricsCollector) *ExtendedMetricsCollector {
 	return &ExtendedMetricsCollector{
 		baseCollector: base,
+		procReader:    infrastructure.NewProcReader(base.config.ProcRoot),
 	}
 }
 
+// Collect implements Collector by collecting extended metrics
+func (c *ExtendedMetricsCollector) Collect(ctx context.Context) (*domain.MetricCollection, error) {
+	return c.CollectWithSystemMetrics(ctx)
+}
+
 // CollectWithSystemMetrics collects both Puma and system metrics
 func (c *ExtendedMetricsCollector) CollectWithSystemMetrics(ctx context.Context) (*domain.MetricCollection, error) {
 	// Get base metrics
-	collection, err := c.baseCollector.Collect(ctx)
+	collection, err := c.baseCollector.collect(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer c.baseCollector.commitRunState()
+
+	// Add Puma process metrics
+	procs := c.pumaProcesses()
+	c.addProcessMemoryMetrics(collection, procs)
+	c.addCPUMetrics(collection, procs)
+	c.addFootprintMetrics(collection)
 
 	// Add system metrics
-	c.addMemoryMetrics(collection)
+	c.addPluginMemoryMetrics(collection)
 	c.addGoroutineMetrics(collection)
 	c.addUptimeMetrics(collection)
 
-	// Try to get GC stats if available
-	c.tryAddGCMetrics(ctx, collection)
-
 	return collection, nil
 }
 
-// addMemoryMetrics adds memory usage metrics
-func (c *ExtendedMetricsCollector) addMemoryMetrics(collection *domain.MetricCollection) {
+// addProcessMemoryMetrics adds memory usage of the Puma master and workers
+// read from procfs, as totals and per process
+func (c *ExtendedMetricsCollector) addProcessMemoryMetrics(collection *domain.MetricCollection, procs []pumaProcess) {
+	timestamp := time.Now()
+	total := infrastructure.ProcessMemory{HasRollup: true}
+	var measured int
+
+	for _, proc := range procs {
+		mem, err := c.procReader.Memory(proc.pid)
+		if err != nil {
+			c.baseCollector.logger.Printf("Process memory not available: %v", err)
+			continue
+		}
+		measured++
+
+		total.RSS += mem.RSS
+		total.PSS += mem.PSS
+		total.USS += mem.USS
+		total.Swap += mem.Swap
+		// Total PSS and USS are only reported when every process has them
+		total.HasRollup = total.HasRollup && mem.HasRollup
+
+		for _, m := range memoryMetrics("process_memory", mem, timestamp) {
+			m.Labels = proc.labels()
+			_ = collection.Add(m)
+		}
+	}
+
+	if measured == 0 {
+		return
+	}
+	for _, m := range memoryMetrics("memory", total, timestamp) {
+		_ = collection.Add(m)
+	}
+}
+
+// pumaProcess is a Puma process measured through procfs
+type pumaProcess struct {
+	name string // worker index, or "master"
+	pid  int
+}
+
+// labels returns the metric labels identifying the process
+func (p pumaProcess) labels() map[string]string {
+	return map[string]string{
+		domain.LabelWorker: p.name,
+		domain.LabelPID:    strconv.Itoa(p.pid),
+	}
+}
+
+// pumaProcesses returns the master and every worker; the master pid comes
+// from the state or pid file, or else from the parent of a worker
+func (c *ExtendedMetricsCollector) pumaProcesses() []pumaProcess {
+	stats := c.baseCollector.LastStats()
+	if stats == nil {
+		return nil
+	}
+
+	var procs []pumaProcess
+
+	masterPID := c.baseCollector.MasterPID()
+	if masterPID == 0 && len(stats.WorkerStatus) > 0 {
+		if ppid, err := c.procReader.ParentPID(stats.WorkerStatus[0].PID); err == nil {
+			masterPID = ppid
+		}
+	}
+	if masterPID > 0 {
+		procs = append(procs, pumaProcess{name: "master", pid: masterPID})
+	}
+
+	for _, worker := range stats.WorkerStatus {
+		procs = append(procs, pumaProcess{name: strconv.Itoa(worker.Index), pid: worker.PID})
+	}
+
+	return procs
+}
+
+// memoryMetrics converts process memory to <group>.rss, .swap, .pss and .uss
+func memoryMetrics(group string, mem infrastructure.ProcessMemory, timestamp time.Time) []domain.Metric {
+	names := []string{"rss", "swap"}
+	values := []uint64{mem.RSS, mem.Swap}
+	if mem.HasRollup {
+		names = append(names, "pss", "uss")
+		values = append(values, mem.PSS, mem.USS)
+	}
+
+	metrics := make([]domain.Metric, 0, len(names))
+	for i, name := range names {
+		metrics = append(metrics, domain.Metric{
+			Name:      group + "." + name,
+			Value:     float64(values[i]),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "bytes",
+			Timestamp: timestamp,
+		})
+	}
+	return metrics
+}
+
+// addCPUMetrics adds CPU utilization of the Puma master and workers since
+// the previous run, as a total and per process. Samples are keyed by pid, so
+// a restarted worker is skipped until it has two samples.
+func (c *ExtendedMetricsCollector) addCPUMetrics(collection *domain.MetricCollection, procs []pumaProcess) {
+	previous := c.baseCollector.runState.Previous()
+	current := c.baseCollector.runState.Current()
+
+	var totalUser, totalSystem float64
+	var measured int
+
+	for _, proc := range procs {
+		cpu, err := c.procReader.CPU(proc.pid)
+		if err != nil {
+			c.baseCollector.logger.Printf("Process CPU not available: %v", err)
+			continue
+		}
+		current.CPU[proc.pid] = cpu
+
+		if previous == nil {
+			continue
+		}
+		last, ok := previous.CPU[proc.pid]
+		if !ok || cpu.User < last.User || cpu.System < last.System {
+			continue
+		}
+
+		elapsed := current.Timestamp.Sub(previous.Timestamp).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		user := cpuPercent(cpu.User-last.User, elapsed)
+		system := cpuPercent(cpu.System-last.System, elapsed)
+		totalUser += user
+		totalSystem += system
+		measured++
+
+		_ = collection.Add(domain.Metric{
+			Name:      "process_cpu.usage",
+			Value:     user + system,
+			Type:      domain.MetricTypeGauge,
+			Unit:      "percentage",
+			Timestamp: current.Timestamp,
+			Labels:    proc.labels(),
+		})
+	}
+
+	if measured == 0 {
+		return
+	}
+
+	_ = collection.Add(domain.Metric{
+		Name:      "cpu.user",
+		Value:     totalUser,
+		Type:      domain.MetricTypeGauge,
+		Unit:      "percentage",
+		Timestamp: current.Timestamp,
+	})
+	_ = collection.Add(domain.Metric{
+		Name:      "cpu.system",
+		Value:     totalSystem,
+		Type:      domain.MetricTypeGauge,
+		Unit:      "percentage",
+		Timestamp: current.Timestamp,
+	})
+	_ = collection.Add(domain.Metric{
+		Name:      "cpu.usage",
+		Value:     totalUser + totalSystem,
+		Type:      domain.MetricTypeGauge,
+		Unit:      "percentage",
+		Timestamp: current.Timestamp,
+	})
+}
+
+// cpuPercent converts clock ticks used over elapsed seconds to a percentage
+// of one CPU
+func cpuPercent(ticks uint64, elapsed float64) float64 {
+	return float64(ticks) / infrastructure.UserHZ / elapsed * 100
+}
+
+// addPluginMemoryMetrics adds the Go runtime memory usage of the plugin itself
+func (c *ExtendedMetricsCollector) addPluginMemoryMetrics(collection *domain.MetricCollection) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
@@ -50,7 +239,7 @@ func (c *ExtendedMetricsCollector) addMemoryMetrics(collection *domain.MetricCol
 
 	// Total allocated memory
 	_ = collection.Add(domain.Metric{
-		Name:      "memory.alloc",
+		Name:      "plugin.memory.alloc",
 		Value:     float64(m.Alloc) / 1024 / 1024, // Convert to MB
 		Type:      domain.MetricTypeGauge,
 		Unit:      "megabytes",
@@ -59,7 +248,7 @@ func (c *ExtendedMetricsCollector) addMemoryMetrics(collection *domain.MetricCol
 
 	// Total system memory
 	_ = collection.Add(domain.Metric{
-		Name:      "memory.sys",
+		Name:      "plugin.memory.sys",
 		Value:     float64(m.Sys) / 1024 / 1024, // Convert to MB
 		Type:      domain.MetricTypeGauge,
 		Unit:      "megabytes",
@@ -68,7 +257,7 @@ func (c *ExtendedMetricsCollector) addMemoryMetrics(collection *domain.MetricCol
 
 	// Heap memory in use
 	_ = collection.Add(domain.Metric{
-		Name:      "memory.heap_inuse",
+		Name:      "plugin.memory.heap_inuse",
 		Value:     float64(m.HeapInuse) / 1024 / 1024, // Convert to MB
 		Type:      domain.MetricTypeGauge,
 		Unit:      "megabytes",
@@ -77,7 +266,7 @@ func (c *ExtendedMetricsCollector) addMemoryMetrics(collection *domain.MetricCol
 
 	// Number of GC cycles
 	_ = collection.Add(domain.Metric{
-		Name:      "gc.num_gc",
+		Name:      "plugin.gc.num_gc",
 		Value:     float64(m.NumGC),
 		Type:      domain.MetricTypeCounter,
 		Unit:      "count",
@@ -90,7 +279,7 @@ func (c *ExtendedMetricsCollector) addGoroutineMetrics(collection *domain.Metric
 	timestamp := time.Now()
 
 	_ = collection.Add(domain.Metric{
-		Name:      "go.goroutines",
+		Name:      "plugin.goroutines",
 		Value:     float64(runtime.NumGoroutine()),
 		Type:      domain.MetricTypeGauge,
 		Unit:      "count",
@@ -112,36 +301,3 @@ func (c *ExtendedMetricsCollector) addUptimeMetrics(collection *domain.MetricCol
 		Timestamp: timestamp,
 	})
 }
-
-// tryAddGCMetrics attempts to add GC metrics from Puma
-func (c *ExtendedMetricsCollector) tryAddGCMetrics(ctx context.Context, collection *domain.MetricCollection) {
-	gcStats, err := c.baseCollector.client.GetGCStats(ctx)
-	if err != nil {
-		// GC stats might not be available, especially in newer Puma versions
-		c.baseCollector.logger.Printf("GC stats not available: %v", err)
-		return
-	}
-
-	// Convert to JSON bytes for the parser
-	gcData, err := json.Marshal(gcStats)
-	if err != nil {
-		c.baseCollector.logger.Printf("Failed to marshal GC stats: %v", err)
-		return
-	}
-
-	// Use the detailed GC parser
-	gcParser := &parsers.GCParser{}
-	gcMetrics, err := gcParser.ParseGCStats(gcData)
-	if err != nil {
-		c.baseCollector.logger.Printf("Failed to parse GC stats: %v", err)
-		return
-	}
-
-	// Add all parsed GC metrics to the collection
-	timestamp := time.Now()
-	for _, metric := range gcMetrics.All() {
-		metric.Timestamp = timestamp
-		_ = collection.Add(metric)
-	}
-}
-
@@ -0,0 +1,206 @@
+package application_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+func TestExtendedMetricsCollector_CPU(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"workers":2,"booted_workers":2,"worker_status":[
+			{"pid":200,"index":0,"last_status":{"running":1,"pool_capacity":5,"max_threads":5}},
+			{"pid":201,"index":1,"last_status":{"running":1,"pool_capacity":5,"max_threads":5}}]}`)
+	}))
+	defer server.Close()
+
+	// Worker 0 used 30s of CPU in the last minute; worker 1 was restarted
+	// (new pid), so it has no previous sample
+	procRoot := t.TempDir()
+	writeStat(t, procRoot, 100, 0, 0)
+	writeStat(t, procRoot, 200, 2500, 500)
+	writeStat(t, procRoot, 201, 10, 10)
+
+	stateFile := filepath.Join(t.TempDir(), "run.state")
+	previous := map[string]any{
+		"timestamp": time.Now().Add(-60 * time.Second),
+		"cpu": map[string]any{
+			"200": map[string]uint64{"user": 0, "system": 0},
+			"199": map[string]uint64{"user": 0, "system": 0},
+		},
+	}
+	data, _ := json.Marshal(previous)
+	if err := os.WriteFile(stateFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.ProcRoot = procRoot
+	config.RunStateFile = stateFile
+
+	collector := application.NewExtendedMetricsCollector(
+		application.NewMetricsCollector(config, log.New(io.Discard, "", 0)),
+	)
+	collection, err := collector.Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	usage := collection.Filter(func(m domain.Metric) bool { return m.Name == "process_cpu.usage" })
+	if len(usage) != 1 || usage[0].Labels[domain.LabelWorker] != "0" {
+		t.Fatalf("expected process_cpu.usage for worker 0 only, got %+v", usage)
+	}
+	if usage[0].Value < 49 || usage[0].Value > 50.1 {
+		t.Errorf("process_cpu.usage = %f, want about 50", usage[0].Value)
+	}
+
+	// The new samples are persisted for the next run
+	var saved application.RunState
+	data, _ = os.ReadFile(stateFile)
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.CPU[201]; !ok {
+		t.Errorf("run state should hold a sample for pid 201: %+v", saved)
+	}
+}
+
+func writeStat(t *testing.T, root string, pid int, utime, stime uint64) {
+	t.Helper()
+	dir := filepath.Join(root, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	stat := fmt.Sprintf("%d (ruby) S 100 100 100 0 -1 0 0 0 0 0 %d %d 0 0 20 0 1 0 0 0 0\n", pid, utime, stime)
+	status := "Name:\truby\nPPid:\t100\n"
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtendedMetricsCollector_MemoryWithoutRollup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"workers":2,"booted_workers":2,"worker_status":[
+			{"pid":200,"index":0,"last_status":{"running":1,"pool_capacity":5,"max_threads":5}},
+			{"pid":201,"index":1,"last_status":{"running":1,"pool_capacity":5,"max_threads":5}}]}`)
+	}))
+	defer server.Close()
+
+	// Only the workers have smaps_rollup; the master, measured first, only
+	// has status
+	procRoot := t.TempDir()
+	for _, pid := range []int{100, 200, 201} {
+		writeStat(t, procRoot, pid, 0, 0)
+		status := "Name:\truby\nPPid:\t100\nVmRSS:\t1024 kB\n"
+		if err := os.WriteFile(filepath.Join(procRoot, fmt.Sprint(pid), "status"), []byte(status), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pid := range []int{200, 201} {
+		rollup := "Rss:                1024 kB\nPss:                 512 kB\nPrivate_Clean:         0 kB\nPrivate_Dirty:       256 kB\nSwap:                  0 kB\n"
+		if err := os.WriteFile(filepath.Join(procRoot, fmt.Sprint(pid), "smaps_rollup"), []byte(rollup), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.ProcRoot = procRoot
+
+	collector := application.NewExtendedMetricsCollector(
+		application.NewMetricsCollector(config, log.New(io.Discard, "", 0)),
+	)
+	collection, err := collector.Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	if got := findValue(collection, "memory.rss"); got == nil || *got != 3*1024*1024 {
+		t.Errorf("memory.rss = %v, want the RSS of all three processes", got)
+	}
+	// A PSS total without the master would be mistaken for all of Puma
+	for _, name := range []string{"memory.pss", "memory.uss"} {
+		if got := findValue(collection, name); got != nil {
+			t.Errorf("%s = %v, want it left out", name, *got)
+		}
+	}
+	if pss := collection.Filter(func(m domain.Metric) bool { return m.Name == "process_memory.pss" }); len(pss) != 2 {
+		t.Errorf("process_memory.pss reported for %d processes, want the 2 workers", len(pss))
+	}
+}
+
+func TestExtendedMetricsCollector_Footprint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"workers":2,"booted_workers":2,"worker_status":[
+			{"pid":200,"index":0,"last_status":{"running":1,"pool_capacity":5,"max_threads":5}},
+			{"pid":201,"index":1,"last_status":{"running":1,"pool_capacity":5,"max_threads":5}}]}`)
+	}))
+	defer server.Close()
+
+	// A 4 CPU host with 8 GiB of memory; each Puma process uses 512 MiB
+	procRoot := t.TempDir()
+	host := map[string]string{
+		"stat":    "cpu  0 0 0 0\ncpu0 0 0 0 0\ncpu1 0 0 0 0\ncpu2 0 0 0 0\ncpu3 0 0 0 0\n",
+		"loadavg": "2.00 1.00 0.50 1/100 1234\n",
+		"meminfo": "MemTotal:        8388608 kB\nMemAvailable:    2097152 kB\n",
+	}
+	for name, content := range host {
+		if err := os.WriteFile(filepath.Join(procRoot, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pid := range []int{100, 200, 201} {
+		writeStat(t, procRoot, pid, 0, 0)
+		status := "Name:\truby\nPPid:\t100\nVmRSS:\t524288 kB\n"
+		if err := os.WriteFile(filepath.Join(procRoot, fmt.Sprint(pid), "status"), []byte(status), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.ProcRoot = procRoot
+
+	collector := application.NewExtendedMetricsCollector(
+		application.NewMetricsCollector(config, log.New(io.Discard, "", 0)),
+	)
+	collection, err := collector.Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	for name, want := range map[string]float64{
+		"footprint.max_threads_per_cpu":      2.5,
+		"footprint.workers_per_cpu":          0.5,
+		"footprint.load_per_cpu":             0.5,
+		"footprint.memory_percent":           18.75,
+		"footprint.memory_available_percent": 25,
+	} {
+		got := findValue(collection, name)
+		if got == nil || *got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	// CPU share needs a previous sample
+	if findValue(collection, "footprint.cpu_percent") != nil {
+		t.Error("footprint.cpu_percent should not be reported on the first run")
+	}
+}
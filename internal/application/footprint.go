@@ -0,0 +1,55 @@
+package application
+
+import (
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// addFootprintMetrics relates Puma's size to the host it runs on, so that
+// hosts of different sizes can be compared: threads and workers per CPU,
+// and memory and CPU as a share of the host. It uses the totals already in
+// collection, so it must run after the process memory and CPU metrics.
+func (c *ExtendedMetricsCollector) addFootprintMetrics(collection *domain.MetricCollection) {
+	host, err := c.procReader.Host()
+	if err != nil {
+		c.baseCollector.logger.Printf("Host info not available: %v", err)
+		return
+	}
+
+	values := make(map[string]float64)
+	for _, metric := range collection.All() {
+		if len(metric.Labels) == 0 {
+			values[metric.Name] = metric.Value
+		}
+	}
+
+	timestamp := time.Now()
+	cpus := float64(host.CPUs)
+	add := func(name string, value float64, unit string) {
+		_ = collection.Add(domain.Metric{
+			Name:      name,
+			Value:     value,
+			Type:      domain.MetricTypeGauge,
+			Unit:      unit,
+			Timestamp: timestamp,
+		})
+	}
+
+	add("footprint.load_per_cpu", host.LoadAvg1/cpus, "ratio")
+	add("footprint.memory_available_percent", float64(host.MemAvailable)/float64(host.MemTotal)*100, "percentage")
+
+	if maxThreads, ok := values["max_threads"]; ok {
+		add("footprint.max_threads_per_cpu", maxThreads/cpus, "ratio")
+	}
+	if workers, ok := values["workers"]; ok {
+		add("footprint.workers_per_cpu", workers/cpus, "ratio")
+	}
+	if rss, ok := values["memory.rss"]; ok {
+		add("footprint.memory_percent", rss/float64(host.MemTotal)*100, "percentage")
+	}
+	// cpu.usage is a percentage of one CPU
+	if usage, ok := values["cpu.usage"]; ok {
+		add("footprint.cpu_percent", usage/cpus, "percentage")
+	}
+}
@@ -0,0 +1,105 @@
+package application
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Instance is one Puma server monitored by a multi-instance run
+type Instance struct {
+	// Name namespaces the instance's metrics, e.g. puma.<name>.workers.workers
+	Name       string
+	SocketPath string
+}
+
+// IsMultiInstance reports whether the -socket values ask for namespaced
+// metrics: more than one socket, a glob, or an explicit name=path mapping.
+// A single plain socket keeps the unprefixed metric keys.
+func IsMultiInstance(specs []string) bool {
+	if len(specs) > 1 {
+		return true
+	}
+	for _, spec := range specs {
+		if _, _, named := strings.Cut(spec, "="); named || isGlob(spec) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseInstances expands -socket values into instances. A value is either
+// a socket path, a glob such as /var/run/puma/*.sock, or name=path; without
+// an explicit name the instance is named after the socket file. A glob may
+// match nothing, e.g. before the apps have booted.
+func ParseInstances(specs []string) ([]Instance, error) {
+	var instances []Instance
+	seen := make(map[string]string)
+
+	add := func(name, path string) error {
+		if !metricPrefixPattern.MatchString(name) {
+			return fmt.Errorf("instance name must consist of letters, digits, '-' and '_', got %q", name)
+		}
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("sockets %s and %s both map to instance %q; use name=path to tell them apart", other, path, name)
+		}
+		seen[name] = path
+		instances = append(instances, Instance{Name: name, SocketPath: path})
+		return nil
+	}
+
+	for _, spec := range specs {
+		name, path, named := strings.Cut(spec, "=")
+		if !named {
+			path = spec
+		}
+		if path == "" {
+			return nil, fmt.Errorf("empty socket path in %q", spec)
+		}
+
+		if !isGlob(path) {
+			if !named {
+				name = InstanceName(path)
+			}
+			if err := add(name, path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if named {
+			return nil, fmt.Errorf("a glob cannot be given an instance name: %q", spec)
+		}
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid socket glob %q: %w", path, err)
+		}
+		for _, match := range matches {
+			if err := add(InstanceName(match), match); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+// InstanceName derives an instance name from a socket path, e.g.
+// /var/run/puma/app1.sock -> app1
+func InstanceName(socketPath string) string {
+	base := filepath.Base(socketPath)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, base)
+}
+
+// isGlob reports whether path contains glob metacharacters
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
@@ -0,0 +1,102 @@
+package application_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+)
+
+func TestParseInstances(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app1.sock", "app2.sock", "other.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []application.Instance
+		wantErr bool
+	}{
+		{
+			name:  "paths",
+			specs: []string{"/var/run/app1/puma.sock", "/var/run/admin.sock"},
+			want: []application.Instance{
+				{Name: "puma", SocketPath: "/var/run/app1/puma.sock"},
+				{Name: "admin", SocketPath: "/var/run/admin.sock"},
+			},
+		},
+		{
+			name:  "named",
+			specs: []string{"app1=/var/run/app1/puma.sock", "app2=/var/run/app2/puma.sock"},
+			want: []application.Instance{
+				{Name: "app1", SocketPath: "/var/run/app1/puma.sock"},
+				{Name: "app2", SocketPath: "/var/run/app2/puma.sock"},
+			},
+		},
+		{
+			name:  "glob",
+			specs: []string{filepath.Join(dir, "*.sock")},
+			want: []application.Instance{
+				{Name: "app1", SocketPath: filepath.Join(dir, "app1.sock")},
+				{Name: "app2", SocketPath: filepath.Join(dir, "app2.sock")},
+			},
+		},
+		{
+			name:    "duplicate names",
+			specs:   []string{"/var/run/app1/puma.sock", "/var/run/app2/puma.sock"},
+			wantErr: true,
+		},
+		{
+			name:  "glob without matches",
+			specs: []string{filepath.Join(dir, "*.socket")},
+			want:  nil,
+		},
+		{
+			name:    "named glob",
+			specs:   []string{"apps=" + filepath.Join(dir, "*.sock")},
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			specs:   []string{"my.app=/tmp/puma.sock"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := application.ParseInstances(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInstances() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("ParseInstances() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsMultiInstance(t *testing.T) {
+	tests := []struct {
+		specs []string
+		want  bool
+	}{
+		{specs: nil, want: false},
+		{specs: []string{"/tmp/puma.sock"}, want: false},
+		{specs: []string{"app=/tmp/puma.sock"}, want: true},
+		{specs: []string{"/var/run/puma/*.sock"}, want: true},
+		{specs: []string{"/tmp/a.sock", "/tmp/b.sock"}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := application.IsMultiInstance(tt.specs); got != tt.want {
+			t.Errorf("IsMultiInstance(%v) = %v, want %v", tt.specs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// Version sources reported in Metadata
+const (
+	// VersionSourceStats means Puma reported its version in /stats
+	VersionSourceStats = "stats"
+	// VersionSourceHeuristic means the major release was guessed from the
+	// fields in /stats
+	VersionSourceHeuristic = "heuristic"
+)
+
+// Metadata describes a Puma instance for Mackerel host metadata
+type Metadata struct {
+	PumaVersion   string `json:"puma_version"`
+	VersionSource string `json:"version_source"`
+	// Mode is single or cluster
+	Mode string `json:"mode"`
+	// The Ruby fields are only known when Puma reports its versions
+	RubyEngine     string `json:"ruby_engine,omitempty"`
+	RubyVersion    string `json:"ruby_version,omitempty"`
+	RubyPatchlevel *int   `json:"ruby_patchlevel,omitempty"`
+}
+
+// Metadata fetches /stats once and describes the Puma instance from it
+func (c *MetricsCollector) Metadata(ctx context.Context) (*Metadata, error) {
+	if err := c.refreshState(); err != nil {
+		return nil, err
+	}
+
+	stats, err := c.client.GetStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	mode := stats.Mode()
+	if c.config.SingleMode {
+		mode = infrastructure.ModeSingle
+	}
+
+	version, fromVersions := infrastructure.DetectStatsVersion(stats)
+	if !fromVersions {
+		return &Metadata{
+			PumaVersion:   version.String(),
+			VersionSource: VersionSourceHeuristic,
+			Mode:          string(mode),
+		}, nil
+	}
+
+	ruby := stats.Versions.Ruby
+	metadata := &Metadata{
+		PumaVersion:   stats.Versions.Puma,
+		VersionSource: VersionSourceStats,
+		Mode:          string(mode),
+		RubyEngine:    ruby.Engine,
+		RubyVersion:   ruby.Version,
+	}
+	if ruby.Version != "" {
+		metadata.RubyPatchlevel = &ruby.Patchlevel
+	}
+	return metadata, nil
+}
@@ -0,0 +1,131 @@
+package application_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+)
+
+func TestMetricsCollector_Metadata(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want application.Metadata
+	}{
+		{
+			name: "versions block",
+			body: `{"backlog":0,"running":1,"pool_capacity":5,"max_threads":5,"busy_threads":0,
+				"versions":{"puma":"7.0.4","ruby":{"engine":"ruby","version":"3.4.5","patchlevel":51}}}`,
+			want: application.Metadata{
+				PumaVersion:   "7.0.4",
+				VersionSource: application.VersionSourceStats,
+				RubyEngine:    "ruby",
+				RubyVersion:   "3.4.5",
+			},
+		},
+		{
+			name: "heuristic",
+			body: `{"backlog":0,"running":1,"pool_capacity":5,"max_threads":5,"busy_threads":0}`,
+			want: application.Metadata{
+				PumaVersion:   "6.x",
+				VersionSource: application.VersionSourceHeuristic,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			config := application.DefaultConfig()
+			config.Host, config.Port = u.Hostname(), u.Port()
+
+			got, err := application.NewMetricsCollector(config, log.New(io.Discard, "", 0)).Metadata(t.Context())
+			if err != nil {
+				t.Fatalf("Metadata() error = %v", err)
+			}
+			if got.PumaVersion != tt.want.PumaVersion || got.VersionSource != tt.want.VersionSource ||
+				got.RubyEngine != tt.want.RubyEngine || got.RubyVersion != tt.want.RubyVersion {
+				t.Errorf("Metadata() = %+v, want %+v", got, tt.want)
+			}
+			if (got.RubyPatchlevel != nil) != (tt.want.RubyVersion != "") {
+				t.Errorf("RubyPatchlevel = %v, want it only with the versions block", got.RubyPatchlevel)
+			}
+		})
+	}
+}
+
+func TestMetricsCollector_RedetectsVersionOnRestart(t *testing.T) {
+	// Puma 6 runs for a while, then is downgraded and restarted
+	bodies := []string{
+		`{"backlog":0,"running":1,"pool_capacity":5,"max_threads":5,"busy_threads":0,"uptime":100}`,
+		`{"backlog":0,"running":1,"pool_capacity":5,"max_threads":5,"uptime":160}`,
+		`{"backlog":0,"running":1,"pool_capacity":5,"max_threads":5,"requests_count":3,"uptime":5}`,
+	}
+	want := []string{"6.x", "6.x", "5.x"}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, bodies[requests.Add(1)-1])
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	collector := application.NewMetricsCollector(config, log.New(io.Discard, "", 0))
+
+	for i := range bodies {
+		if _, err := collector.Collect(t.Context()); err != nil {
+			t.Fatalf("Collect() #%d error = %v", i+1, err)
+		}
+		// The version guessed from the fields is kept until Puma restarts
+		if got := collector.DetectedVersion().String(); got != want[i] {
+			t.Errorf("after Collect() #%d version = %s, want %s", i+1, got, want[i])
+		}
+	}
+}
+
+func TestMetricsCollector_RedetectsVersionAfterBoot(t *testing.T) {
+	// Puma 7 in cluster mode, without a pidfile: the workers have not
+	// reported yet, then they have, then one is restarted
+	bodies := []string{
+		`{"started_at":"2025-01-01T00:00:00Z","workers":1,"booted_workers":0,"worker_status":[]}`,
+		`{"started_at":"2025-01-01T00:00:00Z","workers":1,"booted_workers":1,"worker_status":[{"pid":200,"index":0,"booted":true,
+			"last_status":{"backlog":0,"running":3,"pool_capacity":3,"busy_threads":0,"max_threads":3,"backlog_max":0,"reactor_max":0}}]}`,
+		`{"started_at":"2025-01-01T00:00:00Z","workers":1,"booted_workers":0,"worker_status":[{"pid":201,"index":0,"booted":false,
+			"last_status":{}}]}`,
+	}
+	want := []string{"5.x", "7.x", "7.x"}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, bodies[requests.Add(1)-1])
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.ProcRoot = t.TempDir()
+	collector := application.NewMetricsCollector(config, log.New(io.Discard, "", 0))
+
+	for i := range bodies {
+		if _, err := collector.Collect(t.Context()); err != nil {
+			t.Fatalf("Collect() #%d error = %v", i+1, err)
+		}
+		if got := collector.DetectedVersion().String(); got != want[i] {
+			t.Errorf("after Collect() #%d version = %s, want %s", i+1, got, want[i])
+		}
+	}
+}
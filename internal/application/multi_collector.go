@@ -0,0 +1,115 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// MultiCollector collects several Puma instances concurrently and labels
+// every metric with its instance name
+type MultiCollector struct {
+	sockets      []string
+	newCollector func(Instance) Collector
+	// collectors are kept across runs so that long-lived processes (the
+	// exporter) keep each instance's state
+	collectors map[Instance]Collector
+	logger     *log.Logger
+}
+
+// NewMultiCollector creates a collector for the instances sockets expand
+// to (see ParseInstances), building one collector per instance with
+// newCollector
+func NewMultiCollector(sockets []string, newCollector func(Instance) Collector, logger *log.Logger) *MultiCollector {
+	return &MultiCollector{
+		sockets:      sockets,
+		newCollector: newCollector,
+		collectors:   make(map[Instance]Collector),
+		logger:       logger,
+	}
+}
+
+// Collect collects all instances. An instance that fails is reported with
+// up=0 and does not affect the others; an error is returned only when every
+// instance failed.
+func (m *MultiCollector) Collect(ctx context.Context) (*domain.MetricCollection, error) {
+	// Globs are expanded on every run to pick up apps deployed since
+	instances, err := ParseInstances(m.sockets)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no Puma control sockets match %s", strings.Join(m.sockets, ", "))
+	}
+
+	collectors := make(map[Instance]Collector, len(instances))
+	for _, instance := range instances {
+		collector, ok := m.collectors[instance]
+		if !ok {
+			collector = m.newCollector(instance)
+		}
+		collectors[instance] = collector
+	}
+	m.collectors = collectors
+
+	results := make([]*domain.MetricCollection, len(instances))
+	errs := make([]error, len(instances))
+
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = collectors[instance].Collect(ctx)
+		}()
+	}
+	wg.Wait()
+
+	collection := domain.NewMetricCollection()
+	now := time.Now()
+	failed := 0
+	for i, instance := range instances {
+		up := 1.0
+		if errs[i] != nil {
+			m.logger.Printf("Instance %s: %v", instance.Name, errs[i])
+			errs[i] = fmt.Errorf("instance %s: %w", instance.Name, errs[i])
+			failed++
+			up = 0
+		} else {
+			for _, metric := range results[i].All() {
+				metric.Labels = withInstance(metric.Labels, instance.Name)
+				_ = collection.Add(metric)
+			}
+		}
+
+		_ = collection.Add(domain.Metric{
+			Name:      "up",
+			Value:     up,
+			Type:      domain.MetricTypeGauge,
+			Timestamp: now,
+			Labels:    map[string]string{domain.LabelInstance: instance.Name},
+		})
+	}
+
+	if failed == len(instances) {
+		return nil, errors.Join(errs...)
+	}
+	return collection, nil
+}
+
+// withInstance returns a copy of labels with the instance label set
+func withInstance(labels map[string]string, name string) map[string]string {
+	result := maps.Clone(labels)
+	if result == nil {
+		result = make(map[string]string, 1)
+	}
+	result[domain.LabelInstance] = name
+	return result
+}
@@ -0,0 +1,94 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// stubCollector returns a fixed backlog or error
+type stubCollector struct {
+	backlog float64
+	err     error
+}
+
+func (s stubCollector) Collect(context.Context) (*domain.MetricCollection, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	collection := domain.NewMetricCollection()
+	_ = collection.Add(domain.Metric{Name: "backlog", Value: s.backlog, Type: domain.MetricTypeGauge})
+	return collection, nil
+}
+
+// newStubMultiCollector returns a MultiCollector over stubs keyed by
+// instance name
+func newStubMultiCollector(stubs map[string]stubCollector) *application.MultiCollector {
+	var sockets []string
+	for name := range stubs {
+		sockets = append(sockets, name+"=/var/run/"+name+".sock")
+	}
+	return application.NewMultiCollector(sockets, func(instance application.Instance) application.Collector {
+		return stubs[instance.Name]
+	}, log.New(io.Discard, "", 0))
+}
+
+func TestMultiCollector_Collect(t *testing.T) {
+	multi := newStubMultiCollector(map[string]stubCollector{
+		"app1": {backlog: 1},
+		"app2": {err: errors.New("connection refused")},
+		"app3": {backlog: 3},
+	})
+
+	collection, err := multi.Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	got := make(map[string]float64)
+	for _, m := range collection.All() {
+		got[m.Labels[domain.LabelInstance]+"/"+m.Name] = m.Value
+	}
+	want := map[string]float64{
+		"app1/backlog": 1,
+		"app1/up":      1,
+		"app2/up":      0,
+		"app3/backlog": 3,
+		"app3/up":      1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("Collect() = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if v, ok := got[key]; !ok || v != value {
+			t.Errorf("%s = %v, want %v", key, v, value)
+		}
+	}
+}
+
+func TestMultiCollector_AllFailed(t *testing.T) {
+	multi := newStubMultiCollector(map[string]stubCollector{
+		"app1": {err: errors.New("connection refused")},
+		"app2": {err: errors.New("timeout")},
+	})
+
+	if _, err := multi.Collect(t.Context()); err == nil {
+		t.Error("Collect() should fail when every instance failed")
+	}
+}
+
+func TestMultiCollector_NoMatches(t *testing.T) {
+	multi := application.NewMultiCollector([]string{t.TempDir() + "/*.sock"}, func(application.Instance) application.Collector {
+		t.Fatal("no collector should be built")
+		return nil
+	}, log.New(io.Discard, "", 0))
+
+	if _, err := multi.Collect(t.Context()); err == nil {
+		t.Error("Collect() should fail when no socket matches")
+	}
+}
@@ -0,0 +1,94 @@
+package application
+
+import (
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// maxRunStateAge is how old the previous run may be for deltas to be
+// computed, matching go-mackerel-plugin's limit for diff metrics
+const maxRunStateAge = 10 * time.Minute
+
+// RunState is the data carried from one collection to the next
+type RunState struct {
+	Timestamp time.Time `json:"timestamp"`
+	// CPU holds cumulative CPU ticks keyed by pid
+	CPU map[int]infrastructure.ProcessCPU `json:"cpu,omitempty"`
+	// Workers maps worker index to pid
+	Workers map[int]int `json:"workers,omitempty"`
+	// WorkerFirstSeen holds when each worker pid was first seen, for Puma
+	// versions that do not report started_at
+	WorkerFirstSeen map[int]time.Time `json:"worker_first_seen,omitempty"`
+	// Phase is the cluster phase, or nil in single mode
+	Phase *int `json:"phase,omitempty"`
+}
+
+// runStateTracker keeps the previous run's state and persists the current
+// one; without a store the state only lives for the life of the process
+type runStateTracker struct {
+	store    *infrastructure.StateStore
+	loaded   bool
+	previous *RunState
+	current  *RunState
+}
+
+// newRunStateTracker creates a tracker persisting to path, or in memory
+// only when path is empty
+func newRunStateTracker(path string) *runStateTracker {
+	tracker := &runStateTracker{}
+	if path != "" {
+		tracker.store = infrastructure.NewStateStore(path)
+	}
+	return tracker
+}
+
+// begin starts a new run, loading the previous state on first use
+func (t *runStateTracker) begin() error {
+	var err error
+	if !t.loaded && t.store != nil {
+		var state RunState
+		if err = t.store.Load(&state); err == nil && !state.Timestamp.IsZero() {
+			t.previous = &state
+		}
+	}
+	t.loaded = true
+
+	t.current = &RunState{
+		Timestamp:       time.Now(),
+		CPU:             make(map[int]infrastructure.ProcessCPU),
+		Workers:         make(map[int]int),
+		WorkerFirstSeen: make(map[int]time.Time),
+	}
+	return err
+}
+
+// Previous returns the state of the previous run, or nil when there is none
+// recent enough to compute deltas against
+func (t *runStateTracker) Previous() *RunState {
+	if t.previous == nil || t.current == nil {
+		return nil
+	}
+	if t.current.Timestamp.Sub(t.previous.Timestamp) > maxRunStateAge {
+		return nil
+	}
+	return t.previous
+}
+
+// Current returns the state being built for this run
+func (t *runStateTracker) Current() *RunState {
+	return t.current
+}
+
+// commit makes the current run the previous one and persists it
+func (t *runStateTracker) commit() error {
+	if t.current == nil {
+		return nil
+	}
+	t.previous, t.current = t.current, nil
+
+	if t.store == nil {
+		return nil
+	}
+	return t.store.Save(t.previous)
+}
@@ -0,0 +1,98 @@
+package application
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// sample takes the remaining samples evenly over the sample window after
+// first and returns the latest one, with peaks and percentiles over all of
+// them added. A failed sample is skipped rather than failing the run.
+func (c *MetricsCollector) sample(ctx context.Context, first *domain.MetricCollection) *domain.MetricCollection {
+	samples := []*domain.MetricCollection{first}
+
+	ticker := time.NewTicker(c.config.SampleWindow / time.Duration(c.config.SampleCount-1))
+	defer ticker.Stop()
+
+	for range c.config.SampleCount - 1 {
+		select {
+		case <-ctx.Done():
+			c.logger.Printf("Sampling stopped after %d samples: %v", len(samples), ctx.Err())
+			return addSampleMetrics(samples)
+		case <-ticker.C:
+		}
+
+		collection, err := c.fetchOnce(ctx)
+		if err != nil {
+			c.logger.Printf("Skipping sample: %v", err)
+			continue
+		}
+		samples = append(samples, collection)
+	}
+
+	return addSampleMetrics(samples)
+}
+
+// addSampleMetrics adds the sampled.* peaks, average and p95 over samples to
+// the latest sample and returns it. The names don't start with the names
+// they aggregate, which Mackerel wildcard graphs would match by prefix.
+func addSampleMetrics(samples []*domain.MetricCollection) *domain.MetricCollection {
+	latest := samples[len(samples)-1]
+	timestamp := time.Now()
+
+	add := func(name string, value float64, unit string) {
+		_ = latest.Add(domain.Metric{
+			Name:      name,
+			Value:     value,
+			Type:      domain.MetricTypeGauge,
+			Unit:      unit,
+			Timestamp: timestamp,
+		})
+	}
+
+	if backlog := sampleValues(samples, "backlog"); len(backlog) > 0 {
+		add("sampled.backlog_max", slices.Max(backlog), "requests")
+		add("sampled.backlog_avg", mean(backlog), "requests")
+	}
+	if running := sampleValues(samples, "running"); len(running) > 0 {
+		add("sampled.running_max", slices.Max(running), "threads")
+	}
+	if utilization := sampleValues(samples, "thread_utilization"); len(utilization) > 0 {
+		add("sampled.utilization_p95", percentile(utilization, 95), "percentage")
+	}
+
+	return latest
+}
+
+// sampleValues returns the unlabelled value of name in each sample that has it
+func sampleValues(samples []*domain.MetricCollection, name string) []float64 {
+	var values []float64
+	for _, sample := range samples {
+		for _, metric := range sample.All() {
+			if metric.Name == name && len(metric.Labels) == 0 {
+				values = append(values, metric.Value)
+				break
+			}
+		}
+	}
+	return values
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of values
+func percentile(values []float64, p float64) float64 {
+	sorted := slices.Sorted(slices.Values(values))
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
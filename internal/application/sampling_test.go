@@ -0,0 +1,71 @@
+package application_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+)
+
+func TestMetricsCollector_Sampling(t *testing.T) {
+	// A short spike happens between the polls
+	backlogs := []int{0, 2, 12, 1, 0}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := min(int(requests.Add(1))-1, len(backlogs)-1)
+		fmt.Fprintf(w, `{"workers":1,"booted_workers":1,"requests_count":10,"worker_status":[{"pid":200,"index":0,
+			"last_status":{"backlog":%d,"running":%d,"pool_capacity":5,"max_threads":5,"busy_threads":%[2]d}}]}`,
+			backlogs[i], min(backlogs[i], 5))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	config.SampleCount = 5
+	config.SampleWindow = 40 * time.Millisecond
+
+	collection, err := application.NewMetricsCollector(config, log.New(io.Discard, "", 0)).Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	for name, want := range map[string]float64{
+		"backlog":                 0,
+		"sampled.backlog_max":     12,
+		"sampled.backlog_avg":     3,
+		"sampled.running_max":     5,
+		"sampled.utilization_p95": 100,
+	} {
+		got := findValue(collection, name)
+		if got == nil || *got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMetricsCollector_NoSampling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"backlog":3,"running":1,"pool_capacity":4,"max_threads":5}`)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+
+	collection, err := application.NewMetricsCollector(config, log.New(io.Discard, "", 0)).Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if findValue(collection, "sampled.backlog_max") != nil {
+		t.Error("sampled.backlog_max should only be reported when sampling")
+	}
+}
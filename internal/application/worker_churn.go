@@ -0,0 +1,85 @@
+package application
+
+import (
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// addChurnMetrics compares the workers with the previous run and adds
+// restart, phase change and worker age metrics
+func (c *MetricsCollector) addChurnMetrics(collection *domain.MetricCollection) {
+	stats := c.lastStats
+	if stats == nil || len(stats.WorkerStatus) == 0 {
+		return
+	}
+
+	previous := c.runState.Previous()
+	current := c.runState.Current()
+	timestamp := current.Timestamp
+
+	phase := stats.Phase
+	current.Phase = &phase
+
+	var minAge float64
+	for i, worker := range stats.WorkerStatus {
+		current.Workers[worker.Index] = worker.PID
+
+		// Prefer started_at; otherwise use when the pid was first seen
+		started, ok := worker.StartTime()
+		if !ok {
+			started = timestamp
+			if previous != nil {
+				if seen, found := previous.WorkerFirstSeen[worker.PID]; found {
+					started = seen
+				}
+			}
+			current.WorkerFirstSeen[worker.PID] = started
+		}
+
+		age := max(timestamp.Sub(started).Seconds(), 0)
+		if i == 0 || age < minAge {
+			minAge = age
+		}
+	}
+
+	_ = collection.Add(domain.Metric{
+		Name:      "workers.min_age",
+		Value:     minAge,
+		Type:      domain.MetricTypeGauge,
+		Unit:      "seconds",
+		Timestamp: timestamp,
+	})
+
+	if previous == nil || previous.Phase == nil {
+		return
+	}
+
+	// An index slot whose pid changed had its worker killed and respawned
+	var restarts int
+	for index, pid := range current.Workers {
+		if lastPID, ok := previous.Workers[index]; ok && lastPID != pid {
+			restarts++
+		}
+	}
+
+	// The phase resets to 0 when Puma itself restarts
+	phaseChanges := phase - *previous.Phase
+	if phaseChanges < 0 {
+		phaseChanges = phase
+	}
+
+	_ = collection.Add(domain.Metric{
+		Name:      "workers.restarts",
+		Value:     float64(restarts),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "count",
+		Timestamp: timestamp,
+	})
+
+	_ = collection.Add(domain.Metric{
+		Name:      "workers.phase_changes",
+		Value:     float64(phaseChanges),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "count",
+		Timestamp: timestamp,
+	})
+}
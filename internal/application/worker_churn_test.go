@@ -0,0 +1,66 @@
+package application_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/application"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+func TestMetricsCollector_WorkerChurn(t *testing.T) {
+	// Worker 1 is respawned between the runs during a phased restart
+	payloads := []string{
+		`{"workers":2,"phase":3,"worker_status":[{"pid":200,"index":0},{"pid":201,"index":1}]}`,
+		`{"workers":2,"phase":4,"worker_status":[{"pid":200,"index":0},{"pid":305,"index":1}]}`,
+	}
+	run := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, payloads[run])
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := application.DefaultConfig()
+	config.Host, config.Port = u.Hostname(), u.Port()
+	collector := application.NewMetricsCollector(config, log.New(io.Discard, "", 0))
+
+	collection, err := collector.Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if findValue(collection, "workers.restarts") != nil {
+		t.Error("workers.restarts should not be reported without a previous run")
+	}
+
+	run++
+	collection, err = collector.Collect(t.Context())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	for name, want := range map[string]float64{
+		"workers.restarts":      1,
+		"workers.phase_changes": 1,
+		"workers.min_age":       0,
+	} {
+		got := findValue(collection, name)
+		if got == nil || *got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func findValue(collection *domain.MetricCollection, name string) *float64 {
+	for _, m := range collection.All() {
+		if m.Name == name && len(m.Labels) == 0 {
+			value := m.Value
+			return &value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package domain
+
+// ThreadCategory is what a Puma thread was doing when its backtrace was
+// taken, judged by its top frame
+type ThreadCategory string
+
+const (
+	// ThreadIdle is waiting for work in Puma's thread pool
+	ThreadIdle ThreadCategory = "idle"
+	// ThreadDatabase is in a database driver or ActiveRecord
+	ThreadDatabase ThreadCategory = "database"
+	// ThreadHTTP is in Net::HTTP or an HTTP client gem
+	ThreadHTTP ThreadCategory = "http"
+	// ThreadPuma is in Puma itself, e.g. the reactor or the accept loop
+	ThreadPuma ThreadCategory = "puma"
+	// ThreadRuby is running application or other Ruby code
+	ThreadRuby ThreadCategory = "ruby"
+	// ThreadUnknown has no backtrace, e.g. a thread that just finished
+	ThreadUnknown ThreadCategory = "unknown"
+)
+
+// ThreadCategories lists every category in reporting order
+var ThreadCategories = []ThreadCategory{ThreadIdle, ThreadDatabase, ThreadHTTP, ThreadPuma, ThreadRuby, ThreadUnknown}
+
+// BacktraceSummary groups threads by category and identical top frames, so
+// that e.g. 30 idle pool threads are shown once
+type BacktraceSummary struct {
+	Threads int
+	Counts  map[ThreadCategory]int
+	// Groups are ordered by category, then by thread count, largest first
+	Groups []BacktraceGroup
+}
+
+// BacktraceGroup is the threads of one category sharing their top frames
+type BacktraceGroup struct {
+	Category ThreadCategory
+	// Frames are the top frames the threads share, at most the summary depth
+	Frames []string
+	// Threads are the thread names
+	Threads []string
+}
@@ -0,0 +1,45 @@
+package domain
+
+// CheckStatus is the result status of a health check, using the
+// mackerel-check-plugin exit code convention
+type CheckStatus int
+
+const (
+	CheckOK       CheckStatus = 0
+	CheckWarning  CheckStatus = 1
+	CheckCritical CheckStatus = 2
+	CheckUnknown  CheckStatus = 3
+)
+
+// String returns the status name as shown in check output
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckWarning:
+		return "WARNING"
+	case CheckCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// CheckResult holds the outcome of a health check
+type CheckResult struct {
+	Status CheckStatus
+	// Problems lists every failed condition, most severe first
+	Problems []string
+	// Summary describes the state when there are no problems
+	Summary string
+}
+
+// Raise records a problem and escalates the status if it is more severe
+func (r *CheckResult) Raise(status CheckStatus, problem string) {
+	if status > r.Status {
+		r.Status = status
+		r.Problems = append([]string{problem}, r.Problems...)
+		return
+	}
+	r.Problems = append(r.Problems, problem)
+}
@@ -2,6 +2,14 @@ package domain
 
 // MetricDefinitions holds all metric definitions for Puma
 var MetricDefinitions = map[string]MetricDefinition{
+	// Exporter scrape status
+	"up": {
+		Name:  "up",
+		Label: "Whether the control server could be scraped",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+
 	// Worker metrics
 	"workers": {
 		Name:  "workers",
@@ -27,6 +35,13 @@ var MetricDefinitions = map[string]MetricDefinition{
 		Type:  MetricTypeGauge,
 		Unit:  "integer",
 	},
+	// 1 in clustered mode, 0 in single mode
+	"mode": {
+		Name:  "mode",
+		Label: "Cluster Mode",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
 
 	// Thread pool metrics
 	"backlog": {
@@ -41,6 +56,55 @@ var MetricDefinitions = map[string]MetricDefinition{
 		Type:  MetricTypeGauge,
 		Unit:  "integer",
 	},
+
+	"busy_threads": {
+		Name:  "busy_threads",
+		Label: "Busy Threads",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+
+	// Reported by Puma 7 since the previous status; the largest of any
+	// worker in cluster mode
+	"peak_backlog": {
+		Name:  "peak_backlog",
+		Label: "Backlog Peak (Puma)",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"peak_reactor": {
+		Name:  "peak_reactor",
+		Label: "Reactor Queue Peak",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+
+	// Aggregates over the samples of one run (sample_count > 1)
+	"sampled.backlog_max": {
+		Name:  "sampled.backlog_max",
+		Label: "Backlog Peak",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"sampled.backlog_avg": {
+		Name:  "sampled.backlog_avg",
+		Label: "Backlog Average",
+		Type:  MetricTypeGauge,
+		Unit:  "float",
+	},
+	"sampled.running_max": {
+		Name:  "sampled.running_max",
+		Label: "Running Threads Peak",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"sampled.utilization_p95": {
+		Name:  "sampled.utilization_p95",
+		Label: "Thread Utilization p95",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+
 	"pool_capacity": {
 		Name:  "pool_capacity",
 		Label: "Pool Capacity",
@@ -68,33 +132,281 @@ var MetricDefinitions = map[string]MetricDefinition{
 		Unit:  "seconds",
 	},
 
-	// Memory metrics
-	"memory.alloc": {
-		Name:  "memory.alloc",
-		Label: "Memory Allocated",
+	// Worker checkin metrics
+	"workers.max_checkin_age": {
+		Name:  "workers.max_checkin_age",
+		Label: "Max Worker Checkin Age",
+		Type:  MetricTypeGauge,
+		Unit:  "seconds",
+	},
+	"workers.stale": {
+		Name:  "workers.stale",
+		Label: "Stale Workers",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+
+	// Worker churn metrics (compared with the previous run)
+	"workers.restarts": {
+		Name:  "workers.restarts",
+		Label: "Worker Restarts",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.phase_changes": {
+		Name:  "workers.phase_changes",
+		Label: "Phase Changes",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.min_age": {
+		Name:  "workers.min_age",
+		Label: "Youngest Worker Age",
+		Type:  MetricTypeGauge,
+		Unit:  "seconds",
+	},
+
+	// Per-worker metrics (labelled with the worker index)
+	"workers.backlog": {
+		Name:  "workers.backlog",
+		Label: "Worker Backlog",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.running": {
+		Name:  "workers.running",
+		Label: "Worker Running Threads",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.pool_capacity": {
+		Name:  "workers.pool_capacity",
+		Label: "Worker Pool Capacity",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.max_threads": {
+		Name:  "workers.max_threads",
+		Label: "Worker Max Threads",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.checkin_age": {
+		Name:  "workers.checkin_age",
+		Label: "Worker Checkin Age",
+		Type:  MetricTypeGauge,
+		Unit:  "seconds",
+	},
+	"workers.requests_count": {
+		Name:  "workers.requests_count",
+		Label: "Worker Requests Count",
+		Type:  MetricTypeCounter,
+		Unit:  "integer",
+	},
+	"workers.busy_threads": {
+		Name:  "workers.busy_threads",
+		Label: "Worker Busy Threads",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.peak_backlog": {
+		Name:  "workers.peak_backlog",
+		Label: "Worker Backlog Peak",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"workers.peak_reactor": {
+		Name:  "workers.peak_reactor",
+		Label: "Worker Reactor Queue Peak",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+
+	// Puma process memory metrics (master and workers, from procfs)
+	"memory.rss": {
+		Name:  "memory.rss",
+		Label: "Puma Total RSS",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+	"memory.pss": {
+		Name:  "memory.pss",
+		Label: "Puma Total PSS",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+	"memory.uss": {
+		Name:  "memory.uss",
+		Label: "Puma Total USS",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+	"memory.swap": {
+		Name:  "memory.swap",
+		Label: "Puma Total Swap",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+	"process_memory.rss": {
+		Name:  "process_memory.rss",
+		Label: "Process RSS",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+	"process_memory.pss": {
+		Name:  "process_memory.pss",
+		Label: "Process PSS",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+	"process_memory.uss": {
+		Name:  "process_memory.uss",
+		Label: "Process USS",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+	"process_memory.swap": {
+		Name:  "process_memory.swap",
+		Label: "Process Swap",
+		Type:  MetricTypeGauge,
+		Unit:  "bytes",
+	},
+
+	// Puma process CPU metrics (master and workers, from procfs)
+	"cpu.user": {
+		Name:  "cpu.user",
+		Label: "Puma CPU User",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+	"cpu.system": {
+		Name:  "cpu.system",
+		Label: "Puma CPU System",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+	"cpu.usage": {
+		Name:  "cpu.usage",
+		Label: "Puma CPU Usage",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+	"process_cpu.usage": {
+		Name:  "process_cpu.usage",
+		Label: "Process CPU Usage",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+
+	// Footprint relative to the host (CPU count, load and memory from procfs)
+	"footprint.max_threads_per_cpu": {
+		Name:  "footprint.max_threads_per_cpu",
+		Label: "Max Threads per CPU",
+		Type:  MetricTypeGauge,
+		Unit:  "ratio",
+	},
+	"footprint.workers_per_cpu": {
+		Name:  "footprint.workers_per_cpu",
+		Label: "Workers per CPU",
+		Type:  MetricTypeGauge,
+		Unit:  "ratio",
+	},
+	"footprint.load_per_cpu": {
+		Name:  "footprint.load_per_cpu",
+		Label: "Host Load Average per CPU",
+		Type:  MetricTypeGauge,
+		Unit:  "ratio",
+	},
+	"footprint.memory_percent": {
+		Name:  "footprint.memory_percent",
+		Label: "Puma RSS % of Host Memory",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+	"footprint.memory_available_percent": {
+		Name:  "footprint.memory_available_percent",
+		Label: "Host Memory Available %",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+	"footprint.cpu_percent": {
+		Name:  "footprint.cpu_percent",
+		Label: "Puma CPU % of Host CPU",
+		Type:  MetricTypeGauge,
+		Unit:  "percentage",
+	},
+
+	// Thread activity from /thread-backtraces, by top frame
+	"thread_activity.idle": {
+		Name:  "thread_activity.idle",
+		Label: "Threads Idle in the Pool",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"thread_activity.database": {
+		Name:  "thread_activity.database",
+		Label: "Threads in Database Calls",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"thread_activity.http": {
+		Name:  "thread_activity.http",
+		Label: "Threads in HTTP Calls",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"thread_activity.puma": {
+		Name:  "thread_activity.puma",
+		Label: "Threads in Puma",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"thread_activity.ruby": {
+		Name:  "thread_activity.ruby",
+		Label: "Threads in Ruby Code",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+	"thread_activity.unknown": {
+		Name:  "thread_activity.unknown",
+		Label: "Threads without Backtrace",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+
+	// Plugin (Go runtime) metrics
+	"plugin.memory.alloc": {
+		Name:  "plugin.memory.alloc",
+		Label: "Plugin Memory Allocated",
 		Type:  MetricTypeGauge,
 		Unit:  "megabytes",
 	},
-	"memory.sys": {
-		Name:  "memory.sys",
-		Label: "Memory System",
+	"plugin.memory.sys": {
+		Name:  "plugin.memory.sys",
+		Label: "Plugin Memory System",
 		Type:  MetricTypeGauge,
 		Unit:  "megabytes",
 	},
-	"memory.heap_inuse": {
-		Name:  "memory.heap_inuse",
-		Label: "Heap In Use",
+	"plugin.memory.heap_inuse": {
+		Name:  "plugin.memory.heap_inuse",
+		Label: "Plugin Heap In Use",
 		Type:  MetricTypeGauge,
 		Unit:  "megabytes",
 	},
-
-	// GC metrics
-	"gc.num_gc": {
-		Name:  "gc.num_gc",
-		Label: "GC Count",
+	"plugin.gc.num_gc": {
+		Name:  "plugin.gc.num_gc",
+		Label: "Plugin GC Count",
 		Type:  MetricTypeCounter,
 		Unit:  "integer",
 	},
+	"plugin.goroutines": {
+		Name:  "plugin.goroutines",
+		Label: "Plugin Goroutines",
+		Type:  MetricTypeGauge,
+		Unit:  "integer",
+	},
+
+	// GC metrics
 	"ruby.gc.count": {
 		Name:  "ruby.gc.count",
 		Label: "Ruby GC Count",
@@ -122,14 +434,6 @@ var MetricDefinitions = map[string]MetricDefinition{
 		Unit:  "percentage",
 	},
 
-	// Go runtime metrics
-	"go.goroutines": {
-		Name:  "go.goroutines",
-		Label: "Goroutines",
-		Type:  MetricTypeGauge,
-		Unit:  "integer",
-	},
-
 	// Detailed Ruby GC metrics (from lib/gc.go)
 	"ruby.gc.minor_count": {
 		Name:  "ruby.gc.minor_count",
@@ -205,4 +509,4 @@ type MetricDefinition struct {
 	Label string
 	Type  MetricType
 	Unit  string
-}
\ No newline at end of file
+}
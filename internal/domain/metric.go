@@ -14,6 +14,17 @@ const (
 	MetricTypeCounter MetricType = "counter"
 )
 
+// Well-known metric label keys
+const (
+	// LabelWorker holds the Puma worker index of a per-worker metric
+	LabelWorker = "worker"
+	// LabelPID holds the process id a metric was read from
+	LabelPID = "pid"
+	// LabelInstance holds the name of the Puma instance a metric came from
+	// when several are monitored at once
+	LabelInstance = "instance"
+)
+
 // Metric represents a single metric data point
 type Metric struct {
 	Name      string
@@ -83,4 +94,4 @@ func (mc *MetricCollection) Iter() func(func(int, Metric) bool) {
 func (mc *MetricCollection) Clear() {
 	clear(mc.metrics)
 	mc.metrics = mc.metrics[:0]
-}
\ No newline at end of file
+}
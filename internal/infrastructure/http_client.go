@@ -1,65 +1,134 @@
 package infrastructure
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 )
 
-// UnixSocketClient represents a client for Unix socket communication
+// ErrAuthentication is returned when the control server rejects the auth token
+var ErrAuthentication = errors.New("authentication failed")
+
+// MaxResponseSize bounds a control server response body, after
+// decompression, so that a misbehaving server or proxy cannot exhaust memory
+const MaxResponseSize = 16 << 20
+
+// idleConnTimeout closes kept-alive connections that are unused for longer,
+// well below Puma's persistent_timeout (20s by default)
+const idleConnTimeout = 15 * time.Second
+
+// UnixSocketClient is an HTTP/1.1 client for the control server on a Unix
+// socket. Connections are kept alive and reused across requests, so that
+// repeated polling doesn't dial for each one.
 type UnixSocketClient struct {
 	socketPath string
+	token      string
 	timeout    time.Duration
+	httpClient *http.Client
 }
 
 // NewUnixSocketClient creates a new Unix socket client
-func NewUnixSocketClient(socketPath string, timeout time.Duration) *UnixSocketClient {
+func NewUnixSocketClient(socketPath, token string, timeout time.Duration) *UnixSocketClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     idleConnTimeout,
+	}
+
 	return &UnixSocketClient{
 		socketPath: socketPath,
+		token:      token,
 		timeout:    timeout,
+		httpClient: &http.Client{Transport: transport},
 	}
 }
 
-// Get performs a GET request over Unix socket
-func (c *UnixSocketClient) Get(path string) ([]byte, error) {
-	conn, err := net.Dial("unix", c.socketPath)
-	if err != nil {
-		return nil, fmt.Errorf("connecting to unix socket %s: %w", c.socketPath, err)
-	}
-	defer conn.Close()
+// Get performs a GET request over the Unix socket. The request, including
+// reading the body, is bounded by both the client timeout and ctx;
+// cancelling ctx closes the connection, so no I/O outlives the call.
+func (c *UnixSocketClient) Get(ctx context.Context, path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
-	// Set timeout
-	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
-		return nil, fmt.Errorf("setting connection deadline: %w", err)
+	// The host is only sent as the Host header
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+withToken(path, c.token), nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", path, err)
 	}
 
-	// Send HTTP request
-	request := fmt.Sprintf("GET %s HTTP/1.0\r\nHost: localhost\r\n\r\n", path)
-	if _, err := conn.Write([]byte(request)); err != nil {
-		return nil, fmt.Errorf("sending request: %w", err)
+	body, err := doGet(c.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s over unix socket %s: %w", path, c.socketPath, err)
 	}
+	return body, nil
+}
 
-	// Read response
-	reader := bufio.NewReader(conn)
+// CloseIdleConnections closes the kept-alive connections, e.g. before the
+// client is replaced
+func (c *UnixSocketClient) CloseIdleConnections() {
+	c.httpClient.CloseIdleConnections()
+}
 
-	// Parse HTTP response
-	resp, err := http.ReadResponse(reader, nil)
+// doGet sends req and returns the body of a 200 response. Errors are
+// unwrapped from *url.Error, whose message would repeat the URL and token.
+func doGet(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if err := checkStatus(resp); err != nil {
+		// Drain a short error body so the connection can be reused
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return readBody(resp)
+}
+
+// readBody reads a response body up to MaxResponseSize. net/http decodes
+// chunked bodies, and gzip bodies from proxies compressing the responses,
+// as it asks for gzip itself.
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize+1))
 	if err != nil {
 		return nil, fmt.Errorf("reading response body: %w", err)
 	}
-
+	if len(body) > MaxResponseSize {
+		return nil, fmt.Errorf("response body exceeds %d bytes", MaxResponseSize)
+	}
 	return body, nil
-}
\ No newline at end of file
+}
+
+// withToken appends the control server auth token to the request path
+func withToken(path, token string) string {
+	if token == "" {
+		return path
+	}
+	return path + "?token=" + url.QueryEscape(token)
+}
+
+// checkStatus converts a non-200 control server response into an error
+func checkStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: control server returned %d, auth token is missing or does not match activate_control_app's auth_token", ErrAuthentication, resp.StatusCode)
+	default:
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+}
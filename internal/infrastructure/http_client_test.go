@@ -0,0 +1,220 @@
+package infrastructure_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// listenUnix listens on a socket in a short temporary directory, since
+// socket paths are limited to about 100 bytes
+func listenUnix(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "puma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "control.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return listener, path
+}
+
+func TestUnixSocketClient_Get(t *testing.T) {
+	listener, path := listenUnix(t)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = io.WriteString(w, `{"workers":2}`)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	body, err := infrastructure.NewUnixSocketClient(path, "secret", 5*time.Second).Get(t.Context(), "/stats")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(body) != `{"workers":2}` {
+		t.Errorf("Get() = %s", body)
+	}
+
+	_, err = infrastructure.NewUnixSocketClient(path, "wrong", 5*time.Second).Get(t.Context(), "/stats")
+	if !errors.Is(err, infrastructure.ErrAuthentication) {
+		t.Errorf("Get() error = %v, want ErrAuthentication", err)
+	}
+}
+
+func TestUnixSocketClient_ReusesConnections(t *testing.T) {
+	listener, path := listenUnix(t)
+	var conns atomic.Int32
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{}`)
+		}),
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		},
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := infrastructure.NewPumaClient(infrastructure.ClientConfig{SocketPath: path, Timeout: 5 * time.Second})
+	for range 3 {
+		if _, err := client.GetStats(t.Context()); err != nil {
+			t.Fatalf("GetStats() error = %v", err)
+		}
+		if _, err := client.GetGCStats(t.Context()); err != nil {
+			t.Fatalf("GetGCStats() error = %v", err)
+		}
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Errorf("connections = %d, want 1 reused for every request", got)
+	}
+}
+
+func TestUnixSocketClient_Encodings(t *testing.T) {
+	const payload = `{"workers":2}`
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	_, _ = io.WriteString(gz, payload)
+	_ = gz.Close()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "chunked",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, payload[:5])
+				w.(http.Flusher).Flush()
+				_, _ = io.WriteString(w, payload[5:])
+			},
+			want: payload,
+		},
+		{
+			// As sent by a proxy in front of the control app
+			name: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					http.Error(w, "gzip not accepted", http.StatusNotAcceptable)
+					return
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = w.Write(gzipped.Bytes())
+			},
+			want: payload,
+		},
+		{
+			name: "too large",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(bytes.Repeat([]byte(" "), infrastructure.MaxResponseSize+1))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, path := listenUnix(t)
+			server := &http.Server{Handler: tt.handler}
+			go server.Serve(listener)
+			defer server.Close()
+
+			body, err := infrastructure.NewUnixSocketClient(path, "", 5*time.Second).Get(t.Context(), "/stats")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Get() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("Get() = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnixSocketClient_Cancel(t *testing.T) {
+	// The server accepts but never answers, like a wedged Puma
+	listener, path := listenUnix(t)
+	closed := make(chan struct{})
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := infrastructure.NewUnixSocketClient(path, "", 10*time.Second).Get(ctx, "/stats")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Get() returned after %v, want it to stop with ctx", elapsed)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Error("connection was left open after Get() returned")
+	}
+}
+
+func TestPumaClient_GetGCStatsHonorsContext(t *testing.T) {
+	listener, path := listenUnix(t)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	client := infrastructure.NewPumaClient(infrastructure.ClientConfig{SocketPath: path, Timeout: 10 * time.Second})
+	if _, err := client.GetGCStats(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetGCStats() error = %v, want context.Canceled", err)
+	}
+}
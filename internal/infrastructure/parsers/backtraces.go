@@ -0,0 +1,73 @@
+package parsers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// threadRules match the top frame against path fragments; the first rule
+// that matches decides, and a frame no rule matches is Ruby code
+var threadRules = []struct {
+	category  domain.ThreadCategory
+	fragments []string
+}{
+	{domain.ThreadIdle, []string{"/puma/thread_pool.rb"}},
+	{domain.ThreadDatabase, []string{"/active_record/", "/connection_adapters/", "/pg/", "/mysql2", "/trilogy", "/sqlite3", "/sequel/"}},
+	{domain.ThreadHTTP, []string{"/net/http", "/net/protocol.rb", "/faraday", "/excon", "/httpclient", "/httpx/"}},
+	{domain.ThreadPuma, []string{"/puma/", "/nio4r"}},
+}
+
+// TopFrame returns the first frame of backtrace outside Ruby's own
+// <internal:...> code, which says what the thread was doing
+func TopFrame(backtrace []string) (string, bool) {
+	for _, frame := range backtrace {
+		if !strings.HasPrefix(frame, "<internal:") {
+			return frame, true
+		}
+	}
+	return "", false
+}
+
+// ClassifyThread returns the category of a thread by its top frame
+func ClassifyThread(thread infrastructure.ThreadBacktrace) domain.ThreadCategory {
+	frame, ok := TopFrame(thread.Backtrace)
+	if !ok {
+		return domain.ThreadUnknown
+	}
+	for _, rule := range threadRules {
+		for _, fragment := range rule.fragments {
+			if strings.Contains(frame, fragment) {
+				return rule.category
+			}
+		}
+	}
+	return domain.ThreadRuby
+}
+
+// BacktraceParser turns /thread-backtraces into thread counts per category
+type BacktraceParser struct{}
+
+// ParseBacktraces counts the threads in each category as
+// thread_activity.<category> gauges, including categories without threads
+func (p *BacktraceParser) ParseBacktraces(backtraces []infrastructure.ThreadBacktrace) *domain.MetricCollection {
+	counts := make(map[domain.ThreadCategory]int)
+	for _, thread := range backtraces {
+		counts[ClassifyThread(thread)]++
+	}
+
+	collection := domain.NewMetricCollection()
+	timestamp := time.Now()
+	for _, category := range domain.ThreadCategories {
+		_ = collection.Add(domain.Metric{
+			Name:      "thread_activity." + string(category),
+			Value:     float64(counts[category]),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "threads",
+			Timestamp: timestamp,
+		})
+	}
+	return collection
+}
@@ -0,0 +1,105 @@
+package parsers_test
+
+import (
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
+)
+
+func TestClassifyThread(t *testing.T) {
+	tests := []struct {
+		name      string
+		backtrace []string
+		want      domain.ThreadCategory
+	}{
+		{
+			name: "idle pool thread",
+			backtrace: []string{
+				"/app/vendor/bundle/ruby/3.3.0/gems/puma-6.4.2/lib/puma/thread_pool.rb:142:in `sleep'",
+				"/app/vendor/bundle/ruby/3.3.0/gems/puma-6.4.2/lib/puma/thread_pool.rb:142:in `block in spawn_thread'",
+			},
+			want: domain.ThreadIdle,
+		},
+		{
+			name: "idle pool thread on Ruby 3.4",
+			backtrace: []string{
+				"<internal:thread_sync>:18:in 'Thread::ConditionVariable#wait'",
+				"/gems/puma-7.0.4/lib/puma/thread_pool.rb:170:in 'block in Puma::ThreadPool#spawn_thread'",
+			},
+			want: domain.ThreadIdle,
+		},
+		{
+			name: "postgres query",
+			backtrace: []string{
+				"/gems/activerecord-7.1.3/lib/active_record/connection_adapters/postgresql/database_statements.rb:55:in `exec'",
+				"/app/app/models/user.rb:10:in `recent'",
+				"/gems/puma-6.4.2/lib/puma/thread_pool.rb:155:in `block in spawn_thread'",
+			},
+			want: domain.ThreadDatabase,
+		},
+		{
+			name:      "mysql2",
+			backtrace: []string{"/gems/mysql2-0.5.6/lib/mysql2/client.rb:151:in `_query'"},
+			want:      domain.ThreadDatabase,
+		},
+		{
+			name: "net/http",
+			backtrace: []string{
+				"/usr/local/lib/ruby/3.3.0/net/protocol.rb:229:in `wait_readable'",
+				"/usr/local/lib/ruby/3.3.0/net/http.rb:2345:in `request'",
+			},
+			want: domain.ThreadHTTP,
+		},
+		{
+			name:      "faraday",
+			backtrace: []string{"/gems/faraday-2.9.0/lib/faraday/connection.rb:200:in `get'"},
+			want:      domain.ThreadHTTP,
+		},
+		{
+			name:      "reactor",
+			backtrace: []string{"/gems/puma-6.4.2/lib/puma/reactor.rb:76:in `select'"},
+			want:      domain.ThreadPuma,
+		},
+		{
+			name: "application code",
+			backtrace: []string{
+				"/app/app/services/report.rb:42:in `each'",
+				"/gems/puma-6.4.2/lib/puma/thread_pool.rb:155:in `block in spawn_thread'",
+			},
+			want: domain.ThreadRuby,
+		},
+		{
+			name: "no backtrace",
+			want: domain.ThreadUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parsers.ClassifyThread(infrastructure.ThreadBacktrace{Name: "Thread: TID-1", Backtrace: tt.backtrace})
+			if got != tt.want {
+				t.Errorf("ClassifyThread() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacktraceParser_ParseBacktraces(t *testing.T) {
+	idle := []string{"/gems/puma-6.4.2/lib/puma/thread_pool.rb:142:in `sleep'"}
+	backtraces := []infrastructure.ThreadBacktrace{
+		{Name: "Thread: TID-1 puma threadpool 001", Backtrace: idle},
+		{Name: "Thread: TID-2 puma threadpool 002", Backtrace: idle},
+		{Name: "Thread: TID-3 puma threadpool 003", Backtrace: []string{"/app/app/models/user.rb:10:in `find'"}},
+	}
+
+	parser := &parsers.BacktraceParser{}
+	collection := parser.ParseBacktraces(backtraces)
+
+	checkMetric(t, collection, "thread_activity.idle", 2)
+	checkMetric(t, collection, "thread_activity.ruby", 1)
+	// Categories without threads are reported as 0
+	checkMetric(t, collection, "thread_activity.database", 0)
+	checkMetric(t, collection, "thread_activity.unknown", 0)
+}
@@ -11,31 +11,33 @@ type Parser interface {
 }
 
 // ParserFactory creates appropriate parser based on version
-type ParserFactory struct{}
+type ParserFactory struct {
+	options Options
+}
 
 // NewParserFactory creates a new parser factory
-func NewParserFactory() *ParserFactory {
-	return &ParserFactory{}
+func NewParserFactory(options Options) *ParserFactory {
+	return &ParserFactory{
+		options: options,
+	}
 }
 
 // GetParser returns appropriate parser for the given version
-func (f *ParserFactory) GetParser(version string) Parser {
+func (f *ParserFactory) GetParser(version infrastructure.Version) Parser {
+	var parser Parser
 	switch {
-	case isPuma6OrLater(version):
-		return NewV6Parser()
-	case isPuma5(version):
-		return NewV5Parser()
+	case version.AtLeast(7, 0):
+		parser = NewV7Parser(f.options)
+	case version.AtLeast(6, 0):
+		parser = NewV6Parser(f.options)
+	case version.AtLeast(5, 0):
+		parser = NewV5Parser(f.options)
 	default:
-		return NewV4Parser()
+		parser = NewV4Parser(f.options)
 	}
-}
 
-// isPuma6OrLater checks if version is Puma 6.x or later
-func isPuma6OrLater(version string) bool {
-	return version == "6.x" || (len(version) > 0 && version[0] >= '6')
+	if f.options.Raw.Enabled {
+		return NewRawParser(parser, f.options)
+	}
+	return parser
 }
-
-// isPuma5 checks if version is Puma 5.x
-func isPuma5(version string) bool {
-	return version == "5.x" || (len(version) > 0 && version[0] == '5')
-}
\ No newline at end of file
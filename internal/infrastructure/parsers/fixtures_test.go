@@ -0,0 +1,120 @@
+package parsers_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fixtureTime is the time the fixtures are parsed at, shortly after their
+// last checkins
+var fixtureTime = time.Date(2025, 1, 1, 0, 1, 0, 0, time.UTC)
+
+// TestFixtures checks that each testdata/puma<major>_<mode>.json /stats
+// payload is detected as its major version, then parses it with the parser
+// for that version and compares the metrics with
+// testdata/puma<major>_<mode>.golden. Run with -update after an intended
+// change in output.
+func TestFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "puma*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	factory := parsers.NewParserFactory(parsers.Options{
+		PerWorker: true,
+		Now:       func() time.Time { return fixtureTime },
+	})
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			major, err := strconv.Atoi(strings.TrimPrefix(strings.Split(name, "_")[0], "puma"))
+			if err != nil {
+				t.Fatalf("fixture name %s does not start with puma<major>_", name)
+			}
+
+			body, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stats infrastructure.PumaStats
+			if err := json.Unmarshal(body, &stats); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			stats.Raw = body
+
+			if version, _ := infrastructure.DetectStatsVersion(&stats); version.Major != major {
+				t.Errorf("DetectStatsVersion() = %s, want %d.x", version, major)
+			}
+
+			collection, err := factory.GetParser(infrastructure.MajorVersion(major)).Parse(&stats)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			got := formatGolden(collection)
+			if duplicate := findDuplicate(got); duplicate != "" {
+				t.Errorf("metric %s emitted more than once", duplicate)
+			}
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("metrics differ from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// formatGolden renders a collection as sorted "name{labels} value" lines
+func formatGolden(collection *domain.MetricCollection) string {
+	var lines []string
+	for _, metric := range collection.All() {
+		var labels []string
+		for _, key := range slices.Sorted(maps.Keys(metric.Labels)) {
+			labels = append(labels, key+"="+metric.Labels[key])
+		}
+		lines = append(lines, fmt.Sprintf("%s{%s} %g", metric.Name, strings.Join(labels, ","), metric.Value))
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// findDuplicate returns the first metric that appears twice in golden
+// output, ignoring its value
+func findDuplicate(golden string) string {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(golden), "\n") {
+		metric, _, _ := strings.Cut(line, " ")
+		if seen[metric] {
+			return metric
+		}
+		seen[metric] = true
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package parsers
+
+import (
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// addModeMetric adds mode, 1 in clustered mode and 0 in single mode
+func addModeMetric(collection *domain.MetricCollection, mode infrastructure.Mode, timestamp time.Time) {
+	value := 0.0
+	if mode == infrastructure.ModeCluster {
+		value = 1
+	}
+	_ = collection.Add(domain.Metric{
+		Name:      "mode",
+		Value:     value,
+		Type:      domain.MetricTypeGauge,
+		Unit:      "mode",
+		Timestamp: timestamp,
+	})
+}
+
+// addClusterMetrics adds the worker counts and phase of a clustered server
+func addClusterMetrics(collection *domain.MetricCollection, stats *infrastructure.PumaStats, timestamp time.Time) {
+	_ = collection.Add(domain.Metric{
+		Name:      "workers",
+		Value:     float64(stats.Workers),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "count",
+		Timestamp: timestamp,
+	})
+
+	_ = collection.Add(domain.Metric{
+		Name:      "booted_workers",
+		Value:     float64(stats.BootedWorkers),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "count",
+		Timestamp: timestamp,
+	})
+
+	_ = collection.Add(domain.Metric{
+		Name:      "old_workers",
+		Value:     float64(stats.OldWorkers),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "count",
+		Timestamp: timestamp,
+	})
+
+	_ = collection.Add(domain.Metric{
+		Name:      "phase",
+		Value:     float64(stats.Phase),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "phase",
+		Timestamp: timestamp,
+	})
+}
+
+// addSingleThreadMetrics adds the thread pool metrics a single mode server
+// reports at the top level
+func addSingleThreadMetrics(collection *domain.MetricCollection, stats *infrastructure.PumaStats, timestamp time.Time) {
+	if stats.Backlog != nil {
+		_ = collection.Add(domain.Metric{
+			Name:      "backlog",
+			Value:     float64(*stats.Backlog),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "requests",
+			Timestamp: timestamp,
+		})
+	}
+
+	if stats.Running != nil {
+		_ = collection.Add(domain.Metric{
+			Name:      "running",
+			Value:     float64(*stats.Running),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "threads",
+			Timestamp: timestamp,
+		})
+	}
+
+	if stats.PoolCapacity != nil {
+		_ = collection.Add(domain.Metric{
+			Name:      "pool_capacity",
+			Value:     float64(*stats.PoolCapacity),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "threads",
+			Timestamp: timestamp,
+		})
+	}
+
+	if stats.MaxThreads != nil {
+		_ = collection.Add(domain.Metric{
+			Name:      "max_threads",
+			Value:     float64(*stats.MaxThreads),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "threads",
+			Timestamp: timestamp,
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package parsers
+
+import (
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// Options controls optional parser output
+type Options struct {
+	// PerWorker emits workers.<index>.* metrics in addition to cluster totals
+	PerWorker bool
+	// StaleThreshold is the checkin age after which a worker counts as stale;
+	// DefaultStaleThreshold is used when zero
+	StaleThreshold time.Duration
+	// Raw emits numeric stats fields the parsers don't know as raw.* gauges
+	Raw RawOptions
+	// Mode overrides the mode detected from each payload when set
+	Mode infrastructure.Mode
+	// Now returns the time metrics are stamped with and checkin ages are
+	// measured from; time.Now is used when nil
+	Now func() time.Time
+}
+
+// RawOptions controls the generic passthrough of unknown stats fields
+type RawOptions struct {
+	Enabled bool
+	// Allow and Deny are path.Match patterns for the names under raw.,
+	// e.g. stats.* or workers.busy_*; an empty Allow allows everything and
+	// Deny wins over Allow
+	Allow []string
+	Deny  []string
+}
+
+// DefaultStaleThreshold matches Puma's default worker_timeout
+const DefaultStaleThreshold = 60 * time.Second
+
+// staleThreshold returns the configured threshold or the default
+func (o Options) staleThreshold() time.Duration {
+	if o.StaleThreshold > 0 {
+		return o.StaleThreshold
+	}
+	return DefaultStaleThreshold
+}
+
+// now returns the current time from the configured clock
+func (o Options) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// mode returns the configured mode, or the one stats describe
+func (o Options) mode(stats *infrastructure.PumaStats) infrastructure.Mode {
+	if o.Mode != "" {
+		return o.Mode
+	}
+	return stats.Mode()
+}
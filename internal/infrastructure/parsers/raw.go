@@ -0,0 +1,155 @@
+package parsers
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// RawParser wraps a version parser and passes numeric stats fields it
+// doesn't model through as raw.stats.<field> (top level) and
+// raw.workers.<field> (last_status, summed over workers), so that fields
+// added by new Puma releases can be graphed before the plugin knows them
+type RawParser struct {
+	parser  Parser
+	options Options
+}
+
+// NewRawParser creates a raw passthrough around parser, filtered by
+// options.Raw
+func NewRawParser(parser Parser, options Options) *RawParser {
+	return &RawParser{
+		parser:  parser,
+		options: options,
+	}
+}
+
+// knownStatsFields and knownLastStatusFields are the JSON keys modelled by
+// PumaStats and LastStatus, which the version parsers already emit
+var (
+	knownStatsFields      = jsonFields(reflect.TypeFor[infrastructure.PumaStats]())
+	knownLastStatusFields = jsonFields(reflect.TypeFor[infrastructure.LastStatus]())
+)
+
+// Parse parses stats with the wrapped parser and adds the raw metrics
+func (p *RawParser) Parse(stats *infrastructure.PumaStats) (*domain.MetricCollection, error) {
+	collection, err := p.parser.Parse(stats)
+	if err != nil {
+		return nil, err
+	}
+	if len(stats.Raw) == 0 {
+		return collection, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(stats.Raw, &fields); err != nil {
+		return collection, nil
+	}
+	var workers []struct {
+		LastStatus map[string]json.RawMessage `json:"last_status"`
+	}
+	// worker_status is missing in single mode
+	_ = json.Unmarshal(fields["worker_status"], &workers)
+
+	timestamp := p.options.now()
+	for field, value := range fields {
+		if knownStatsFields[field] {
+			continue
+		}
+		if number, ok := rawNumber(value); ok {
+			p.add(collection, "stats."+sanitizeField(field), number, timestamp)
+		}
+	}
+
+	totals := make(map[string]float64)
+	for _, worker := range workers {
+		for field, value := range worker.LastStatus {
+			if knownLastStatusFields[field] {
+				continue
+			}
+			if number, ok := rawNumber(value); ok {
+				totals[sanitizeField(field)] += number
+			}
+		}
+	}
+	for field, total := range totals {
+		p.add(collection, "workers."+field, total, timestamp)
+	}
+
+	return collection, nil
+}
+
+// add adds raw.<name> unless the allow/deny lists filter it out
+func (p *RawParser) add(collection *domain.MetricCollection, name string, value float64, timestamp time.Time) {
+	if !p.allowed(name) {
+		return
+	}
+	_ = collection.Add(domain.Metric{
+		Name:      "raw." + name,
+		Value:     value,
+		Type:      domain.MetricTypeGauge,
+		Timestamp: timestamp,
+	})
+}
+
+// allowed reports whether name matches the allow list and not the deny list
+func (p *RawParser) allowed(name string) bool {
+	if matchAny(p.options.Raw.Deny, name) {
+		return false
+	}
+	return len(p.options.Raw.Allow) == 0 || matchAny(p.options.Raw.Allow, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rawNumber decodes a JSON number; strings, booleans, objects and arrays
+// are not passed through
+func rawNumber(value json.RawMessage) (float64, bool) {
+	text := strings.TrimSpace(string(value))
+	if text == "" || (text[0] != '-' && (text[0] < '0' || text[0] > '9')) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// sanitizeField replaces characters that aren't allowed in a metric name
+// segment
+func sanitizeField(field string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, field)
+}
+
+// jsonFields returns the JSON keys of a struct type's fields
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
@@ -0,0 +1,127 @@
+package parsers_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure/parsers"
+)
+
+const rawStatsBody = `{
+	"workers": 2,
+	"booted_workers": 2,
+	"old_workers": 0,
+	"requests_count": 100,
+	"future_gauge": 7,
+	"future.dotted": 1.5,
+	"version_name": "Sky's Version",
+	"flags": true,
+	"quoted": "12",
+	"worker_status": [
+		{"pid": 1, "index": 0, "booted": true, "last_status": {"backlog": 1, "running": 2, "pool_capacity": 3, "max_threads": 5, "io_wait": 4, "note": "x"}},
+		{"pid": 2, "index": 1, "booted": true, "last_status": {"backlog": 0, "running": 1, "pool_capacity": 4, "max_threads": 5, "io_wait": 6}}
+	]
+}`
+
+func parseRaw(t *testing.T, body string, options parsers.RawOptions) map[string]float64 {
+	t.Helper()
+	var stats infrastructure.PumaStats
+	if err := json.Unmarshal([]byte(body), &stats); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	stats.Raw = json.RawMessage(body)
+
+	options.Enabled = true
+	factory := parsers.NewParserFactory(parsers.Options{Raw: options})
+	collection, err := factory.GetParser(infrastructure.MajorVersion(6)).Parse(&stats)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	raw := make(map[string]float64)
+	for _, metric := range collection.All() {
+		if strings.HasPrefix(metric.Name, "raw.") {
+			raw[metric.Name] = metric.Value
+		}
+	}
+	return raw
+}
+
+func TestRawParser_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		options parsers.RawOptions
+		want    map[string]float64
+	}{
+		{
+			name: "unknown numeric fields only",
+			body: rawStatsBody,
+			want: map[string]float64{
+				"raw.stats.future_gauge":  7,
+				"raw.stats.future_dotted": 1.5,
+				"raw.workers.io_wait":     10,
+			},
+		},
+		{
+			name:    "allow list",
+			body:    rawStatsBody,
+			options: parsers.RawOptions{Allow: []string{"workers.*"}},
+			want: map[string]float64{
+				"raw.workers.io_wait": 10,
+			},
+		},
+		{
+			name: "deny wins over allow",
+			body: rawStatsBody,
+			options: parsers.RawOptions{
+				Allow: []string{"stats.*"},
+				Deny:  []string{"stats.future_dotted"},
+			},
+			want: map[string]float64{
+				"raw.stats.future_gauge": 7,
+			},
+		},
+		{
+			name: "single mode",
+			body: `{"backlog": 0, "running": 1, "pool_capacity": 5, "max_threads": 5, "requests_count": 3, "future_gauge": 2}`,
+			want: map[string]float64{
+				"raw.stats.future_gauge": 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRaw(t, tt.body, tt.options)
+			if len(got) != len(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if value, ok := got[name]; !ok || value != want {
+					t.Errorf("%s: got %v (found %v), want %v", name, value, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRawParser_Disabled(t *testing.T) {
+	var stats infrastructure.PumaStats
+	if err := json.Unmarshal([]byte(rawStatsBody), &stats); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	stats.Raw = json.RawMessage(rawStatsBody)
+
+	collection, err := parsers.NewParserFactory(parsers.Options{}).GetParser(infrastructure.MajorVersion(6)).Parse(&stats)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	for _, metric := range collection.All() {
+		if strings.HasPrefix(metric.Name, "raw.") {
+			t.Errorf("unexpected raw metric %s without Raw.Enabled", metric.Name)
+		}
+	}
+}
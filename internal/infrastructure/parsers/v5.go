@@ -1,57 +1,36 @@
 package parsers
 
 import (
-	"time"
-
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
 )
 
 // V5Parser parses Puma v5.x stats
-type V5Parser struct{}
+type V5Parser struct {
+	options Options
+}
 
 // NewV5Parser creates a new V5 parser
-func NewV5Parser() *V5Parser {
-	return &V5Parser{}
+func NewV5Parser(options Options) *V5Parser {
+	return &V5Parser{
+		options: options,
+	}
 }
 
 // Parse converts PumaStats to MetricCollection for v5.x
 func (p *V5Parser) Parse(stats *infrastructure.PumaStats) (*domain.MetricCollection, error) {
 	collection := domain.NewMetricCollection()
-	timestamp := time.Now()
+	timestamp := p.options.now()
 
-	// Worker metrics
-	_ = collection.Add(domain.Metric{
-		Name:      "workers",
-		Value:     float64(stats.Workers),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "count",
-		Timestamp: timestamp,
-	})
+	mode := p.options.mode(stats)
+	addModeMetric(collection, mode, timestamp)
 
-	_ = collection.Add(domain.Metric{
-		Name:      "booted_workers",
-		Value:     float64(stats.BootedWorkers),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "count",
-		Timestamp: timestamp,
-	})
-
-	_ = collection.Add(domain.Metric{
-		Name:      "old_workers",
-		Value:     float64(stats.OldWorkers),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "count",
-		Timestamp: timestamp,
-	})
+	if mode == infrastructure.ModeSingle {
+		addSingleThreadMetrics(collection, stats, timestamp)
+		return collection, nil
+	}
 
-	_ = collection.Add(domain.Metric{
-		Name:      "phase",
-		Value:     float64(stats.Phase),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "phase",
-		Timestamp: timestamp,
-	})
+	addClusterMetrics(collection, stats, timestamp)
 
 	// Process worker status
 	var totalBacklog, totalRunning, totalPoolCapacity, totalMaxThreads int
@@ -97,46 +76,10 @@ func (p *V5Parser) Parse(stats *infrastructure.PumaStats) (*domain.MetricCollect
 		})
 	}
 
-	// Single mode metrics
-	if stats.Backlog != nil {
-		_ = collection.Add(domain.Metric{
-			Name:      "backlog",
-			Value:     float64(*stats.Backlog),
-			Type:      domain.MetricTypeGauge,
-			Unit:      "requests",
-			Timestamp: timestamp,
-		})
-	}
-
-	if stats.Running != nil {
-		_ = collection.Add(domain.Metric{
-			Name:      "running",
-			Value:     float64(*stats.Running),
-			Type:      domain.MetricTypeGauge,
-			Unit:      "threads",
-			Timestamp: timestamp,
-		})
-	}
-
-	if stats.PoolCapacity != nil {
-		_ = collection.Add(domain.Metric{
-			Name:      "pool_capacity",
-			Value:     float64(*stats.PoolCapacity),
-			Type:      domain.MetricTypeGauge,
-			Unit:      "threads",
-			Timestamp: timestamp,
-		})
-	}
-
-	if stats.MaxThreads != nil {
-		_ = collection.Add(domain.Metric{
-			Name:      "max_threads",
-			Value:     float64(*stats.MaxThreads),
-			Type:      domain.MetricTypeGauge,
-			Unit:      "threads",
-			Timestamp: timestamp,
-		})
+	if p.options.PerWorker {
+		addWorkerMetrics(collection, stats.WorkerStatus, timestamp)
 	}
+	addCheckinMetrics(collection, stats.WorkerStatus, p.options, timestamp)
 
 	return collection, nil
-}
\ No newline at end of file
+}
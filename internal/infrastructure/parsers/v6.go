@@ -8,50 +8,24 @@ import (
 )
 
 // V6Parser parses Puma v6.x stats
-type V6Parser struct{}
+type V6Parser struct {
+	options Options
+}
 
 // NewV6Parser creates a new V6 parser
-func NewV6Parser() *V6Parser {
-	return &V6Parser{}
+func NewV6Parser(options Options) *V6Parser {
+	return &V6Parser{
+		options: options,
+	}
 }
 
 // Parse converts PumaStats to MetricCollection
 func (p *V6Parser) Parse(stats *infrastructure.PumaStats) (*domain.MetricCollection, error) {
 	collection := domain.NewMetricCollection()
-	timestamp := time.Now()
+	timestamp := p.options.now()
 
-	// Worker metrics
-	_ = collection.Add(domain.Metric{
-		Name:      "workers",
-		Value:     float64(stats.Workers),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "count",
-		Timestamp: timestamp,
-	})
-
-	_ = collection.Add(domain.Metric{
-		Name:      "booted_workers",
-		Value:     float64(stats.BootedWorkers),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "count",
-		Timestamp: timestamp,
-	})
-
-	_ = collection.Add(domain.Metric{
-		Name:      "old_workers",
-		Value:     float64(stats.OldWorkers),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "count",
-		Timestamp: timestamp,
-	})
-
-	_ = collection.Add(domain.Metric{
-		Name:      "phase",
-		Value:     float64(stats.Phase),
-		Type:      domain.MetricTypeGauge,
-		Unit:      "phase",
-		Timestamp: timestamp,
-	})
+	mode := p.options.mode(stats)
+	addModeMetric(collection, mode, timestamp)
 
 	// New in Puma 6.x: Request count
 	if stats.RequestsCount != nil {
@@ -75,17 +49,39 @@ func (p *V6Parser) Parse(stats *infrastructure.PumaStats) (*domain.MetricCollect
 		})
 	}
 
+	if mode == infrastructure.ModeSingle {
+		addSingleThreadMetrics(collection, stats, timestamp)
+		if stats.BusyThreads != nil {
+			maxThreads := 0
+			if stats.MaxThreads != nil {
+				maxThreads = *stats.MaxThreads
+			}
+			addBusyThreadMetrics(collection, *stats.BusyThreads, maxThreads, timestamp)
+		}
+		return collection, nil
+	}
+
+	addClusterMetrics(collection, stats, timestamp)
+
 	// Process worker status
-	var totalBacklog, totalRunning, totalPoolCapacity, totalMaxThreads int
+	var totalBacklog, totalRunning, totalPoolCapacity, totalMaxThreads, totalBusy int
+	hasBusy := len(stats.WorkerStatus) > 0
 	for _, worker := range stats.WorkerStatus {
 		totalBacklog += worker.LastStatus.Backlog
 		totalRunning += worker.LastStatus.Running
 		totalPoolCapacity += worker.LastStatus.PoolCapacity
 		totalMaxThreads += worker.LastStatus.MaxThreads
+		if worker.LastStatus.BusyThreads != nil {
+			totalBusy += *worker.LastStatus.BusyThreads
+		} else {
+			hasBusy = false
+		}
 	}
 
-	// Calculate thread utilization
-	if totalPoolCapacity > 0 {
+	// Calculate thread utilization, from busy_threads on Puma 6.6+
+	if hasBusy {
+		addBusyThreadMetrics(collection, totalBusy, totalMaxThreads, timestamp)
+	} else if totalPoolCapacity > 0 {
 		utilization := (float64(totalRunning) / float64(totalPoolCapacity)) * 100
 		_ = collection.Add(domain.Metric{
 			Name:      "thread_utilization",
@@ -131,46 +127,32 @@ func (p *V6Parser) Parse(stats *infrastructure.PumaStats) (*domain.MetricCollect
 		})
 	}
 
-	// Single mode metrics
-	if stats.Backlog != nil {
-		_ = collection.Add(domain.Metric{
-			Name:      "backlog",
-			Value:     float64(*stats.Backlog),
-			Type:      domain.MetricTypeGauge,
-			Unit:      "requests",
-			Timestamp: timestamp,
-		})
+	if p.options.PerWorker {
+		addWorkerMetrics(collection, stats.WorkerStatus, timestamp)
 	}
+	addCheckinMetrics(collection, stats.WorkerStatus, p.options, timestamp)
 
-	if stats.Running != nil {
-		_ = collection.Add(domain.Metric{
-			Name:      "running",
-			Value:     float64(*stats.Running),
-			Type:      domain.MetricTypeGauge,
-			Unit:      "threads",
-			Timestamp: timestamp,
-		})
-	}
+	return collection, nil
+}
 
-	if stats.PoolCapacity != nil {
-		_ = collection.Add(domain.Metric{
-			Name:      "pool_capacity",
-			Value:     float64(*stats.PoolCapacity),
-			Type:      domain.MetricTypeGauge,
-			Unit:      "threads",
-			Timestamp: timestamp,
-		})
-	}
+// addBusyThreadMetrics adds busy_threads and the thread utilization derived
+// from it, which unlike running counts only threads serving a request
+func addBusyThreadMetrics(collection *domain.MetricCollection, busy, maxThreads int, timestamp time.Time) {
+	_ = collection.Add(domain.Metric{
+		Name:      "busy_threads",
+		Value:     float64(busy),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "threads",
+		Timestamp: timestamp,
+	})
 
-	if stats.MaxThreads != nil {
+	if maxThreads > 0 {
 		_ = collection.Add(domain.Metric{
-			Name:      "max_threads",
-			Value:     float64(*stats.MaxThreads),
+			Name:      "thread_utilization",
+			Value:     float64(busy) / float64(maxThreads) * 100,
 			Type:      domain.MetricTypeGauge,
-			Unit:      "threads",
+			Unit:      "percentage",
 			Timestamp: timestamp,
 		})
 	}
-
-	return collection, nil
-}
\ No newline at end of file
+}
@@ -2,6 +2,7 @@ package parsers_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
 	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
@@ -9,7 +10,7 @@ import (
 )
 
 func TestV6Parser_Parse(t *testing.T) {
-	parser := parsers.NewV6Parser()
+	parser := parsers.NewV6Parser(parsers.Options{})
 
 	t.Run("cluster mode metrics", func(t *testing.T) {
 		stats := &infrastructure.PumaStats{
@@ -21,9 +22,9 @@ func TestV6Parser_Parse(t *testing.T) {
 			Uptime:        intPtr(3600),
 			WorkerStatus: []infrastructure.WorkerStatus{
 				{
-					PID:   1234,
-					Index: 0,
-					Phase: 0,
+					PID:    1234,
+					Index:  0,
+					Phase:  0,
 					Booted: true,
 					LastStatus: infrastructure.LastStatus{
 						Backlog:      0,
@@ -33,9 +34,9 @@ func TestV6Parser_Parse(t *testing.T) {
 					},
 				},
 				{
-					PID:   1235,
-					Index: 1,
-					Phase: 0,
+					PID:    1235,
+					Index:  1,
+					Phase:  0,
 					Booted: true,
 					LastStatus: infrastructure.LastStatus{
 						Backlog:      2,
@@ -96,6 +97,131 @@ func TestV6Parser_Parse(t *testing.T) {
 		checkMetric(t, collection, "max_threads", 20.0)
 	})
 
+	t.Run("mode override", func(t *testing.T) {
+		stats := &infrastructure.PumaStats{
+			Workers:       1,
+			BootedWorkers: 1,
+			Backlog:       intPtr(5),
+			Running:       intPtr(1),
+			WorkerStatus: []infrastructure.WorkerStatus{
+				{PID: 1234, LastStatus: infrastructure.LastStatus{Backlog: 2, Running: 3}},
+			},
+		}
+
+		collection, err := parsers.NewV6Parser(parsers.Options{Mode: infrastructure.ModeSingle}).Parse(stats)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		checkMetric(t, collection, "mode", 0)
+		checkMetric(t, collection, "backlog", 5)
+		if findMetric(collection, "workers") != nil {
+			t.Error("workers should not be reported in single mode")
+		}
+	})
+
+	t.Run("per-worker metrics", func(t *testing.T) {
+		parser := parsers.NewV6Parser(parsers.Options{PerWorker: true})
+		stats := &infrastructure.PumaStats{
+			Workers: 2,
+			WorkerStatus: []infrastructure.WorkerStatus{
+				{PID: 1234, Index: 0, LastStatus: infrastructure.LastStatus{Backlog: 0, Running: 5, PoolCapacity: 11}},
+				{PID: 1235, Index: 1, LastStatus: infrastructure.LastStatus{Backlog: 7, Running: 16, PoolCapacity: 0, RequestsCount: int64Ptr(42)}},
+			},
+		}
+
+		collection, err := parser.Parse(stats)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		backlogs := collection.Filter(func(m domain.Metric) bool { return m.Name == "workers.backlog" })
+		if len(backlogs) != 2 {
+			t.Fatalf("expected 2 workers.backlog metrics, got %d", len(backlogs))
+		}
+		if backlogs[1].Labels[domain.LabelWorker] != "1" || backlogs[1].Value != 7 {
+			t.Errorf("unexpected worker 1 backlog: %+v", backlogs[1])
+		}
+
+		requests := collection.Filter(func(m domain.Metric) bool { return m.Name == "workers.requests_count" })
+		if len(requests) != 1 || requests[0].Labels[domain.LabelPID] != "1235" {
+			t.Errorf("unexpected workers.requests_count: %+v", requests)
+		}
+
+		// Cluster totals are still emitted
+		checkMetric(t, collection, "backlog", 7.0)
+	})
+
+	t.Run("stale workers from last_checkin", func(t *testing.T) {
+		parser := parsers.NewV6Parser(parsers.Options{StaleThreshold: 30 * time.Second})
+		now := time.Now().UTC()
+		stats := &infrastructure.PumaStats{
+			Workers: 3,
+			WorkerStatus: []infrastructure.WorkerStatus{
+				{Index: 0, LastCheckin: now.Add(-5 * time.Second).Format(time.RFC3339)},
+				{Index: 1, LastCheckin: now.Add(-45 * time.Second).Format(time.RFC3339)},
+				{Index: 2, LastCheckin: "not a time"},
+			},
+		}
+
+		collection, err := parser.Parse(stats)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		checkMetric(t, collection, "workers.stale", 1.0)
+		age := findMetric(collection, "workers.max_checkin_age")
+		if age == nil || age.Value < 44 || age.Value > 47 {
+			t.Errorf("workers.max_checkin_age = %+v, want about 45", age)
+		}
+	})
+
+	t.Run("busy threads", func(t *testing.T) {
+		parser := parsers.NewV6Parser(parsers.Options{PerWorker: true})
+		stats := &infrastructure.PumaStats{
+			Workers: 2,
+			WorkerStatus: []infrastructure.WorkerStatus{
+				{PID: 1234, Index: 0, LastStatus: infrastructure.LastStatus{Running: 5, PoolCapacity: 4, MaxThreads: 5, BusyThreads: intPtr(1), RequestsCount: int64Ptr(10)}},
+				{PID: 1235, Index: 1, LastStatus: infrastructure.LastStatus{Running: 5, PoolCapacity: 2, MaxThreads: 5, BusyThreads: intPtr(3), RequestsCount: int64Ptr(20)}},
+			},
+		}
+
+		collection, err := parser.Parse(stats)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		checkMetric(t, collection, "busy_threads", 4.0)
+		checkMetric(t, collection, "thread_utilization", 40.0) // 4/10 * 100
+
+		busy := collection.Filter(func(m domain.Metric) bool { return m.Name == "workers.busy_threads" })
+		if len(busy) != 2 || busy[1].Labels[domain.LabelWorker] != "1" || busy[1].Value != 3 {
+			t.Errorf("unexpected workers.busy_threads: %+v", busy)
+		}
+		requests := collection.Filter(func(m domain.Metric) bool { return m.Name == "workers.requests_count" })
+		if len(requests) != 2 {
+			t.Errorf("expected 2 workers.requests_count metrics, got %+v", requests)
+		}
+	})
+
+	t.Run("single mode busy threads", func(t *testing.T) {
+		stats := &infrastructure.PumaStats{
+			Backlog:      intPtr(0),
+			Running:      intPtr(5),
+			PoolCapacity: intPtr(3),
+			MaxThreads:   intPtr(5),
+			BusyThreads:  intPtr(2),
+		}
+
+		collection, err := parser.Parse(stats)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		checkMetric(t, collection, "busy_threads", 2.0)
+		checkMetric(t, collection, "thread_utilization", 40.0)
+	})
+
 	t.Run("no thread utilization when pool capacity is zero", func(t *testing.T) {
 		stats := &infrastructure.PumaStats{
 			Workers: 1,
@@ -149,4 +275,4 @@ func intPtr(i int) *int {
 
 func int64Ptr(i int64) *int64 {
 	return &i
-}
\ No newline at end of file
+}
@@ -0,0 +1,73 @@
+package parsers
+
+import (
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// V7Parser parses Puma v7.x stats. The fields of 6.x are unchanged; Puma 7
+// adds the peak backlog and reactor queue sizes since the previous status.
+type V7Parser struct {
+	v6      *V6Parser
+	options Options
+}
+
+// NewV7Parser creates a new V7 parser
+func NewV7Parser(options Options) *V7Parser {
+	return &V7Parser{
+		v6:      NewV6Parser(options),
+		options: options,
+	}
+}
+
+// Parse converts PumaStats to MetricCollection
+func (p *V7Parser) Parse(stats *infrastructure.PumaStats) (*domain.MetricCollection, error) {
+	collection, err := p.v6.Parse(stats)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := p.options.now()
+
+	// Single mode reports the peaks at the top level. In clustered mode the
+	// largest peak of any worker is reported, since the workers' peaks
+	// need not coincide.
+	backlogMax, reactorMax := stats.BacklogMax, stats.ReactorMax
+	if p.options.mode(stats) == infrastructure.ModeCluster {
+		backlogMax, reactorMax = nil, nil
+		for _, worker := range stats.WorkerStatus {
+			backlogMax = maxOf(backlogMax, worker.LastStatus.BacklogMax)
+			reactorMax = maxOf(reactorMax, worker.LastStatus.ReactorMax)
+		}
+	}
+
+	// Named peak_* rather than Puma's *_max so that no name starts with
+	// backlog, which Mackerel wildcard graphs would match by prefix
+	if backlogMax != nil {
+		addPeakMetric(collection, "peak_backlog", *backlogMax, timestamp)
+	}
+	if reactorMax != nil {
+		addPeakMetric(collection, "peak_reactor", *reactorMax, timestamp)
+	}
+
+	return collection, nil
+}
+
+// maxOf returns the larger of two optional values
+func maxOf(a, b *int) *int {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+	return a
+}
+
+func addPeakMetric(collection *domain.MetricCollection, name string, value int, timestamp time.Time) {
+	_ = collection.Add(domain.Metric{
+		Name:      name,
+		Value:     float64(value),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "requests",
+		Timestamp: timestamp,
+	})
+}
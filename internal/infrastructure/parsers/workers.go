@@ -0,0 +1,154 @@
+package parsers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+// addWorkerMetrics adds one set of thread pool metrics per worker, labelled
+// with the worker index and pid
+func addWorkerMetrics(collection *domain.MetricCollection, workers []infrastructure.WorkerStatus, timestamp time.Time) {
+	for _, worker := range workers {
+		labels := map[string]string{
+			domain.LabelWorker: strconv.Itoa(worker.Index),
+			domain.LabelPID:    strconv.Itoa(worker.PID),
+		}
+
+		_ = collection.Add(domain.Metric{
+			Name:      "workers.backlog",
+			Value:     float64(worker.LastStatus.Backlog),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "requests",
+			Timestamp: timestamp,
+			Labels:    labels,
+		})
+
+		_ = collection.Add(domain.Metric{
+			Name:      "workers.running",
+			Value:     float64(worker.LastStatus.Running),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "threads",
+			Timestamp: timestamp,
+			Labels:    labels,
+		})
+
+		_ = collection.Add(domain.Metric{
+			Name:      "workers.pool_capacity",
+			Value:     float64(worker.LastStatus.PoolCapacity),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "threads",
+			Timestamp: timestamp,
+			Labels:    labels,
+		})
+
+		_ = collection.Add(domain.Metric{
+			Name:      "workers.max_threads",
+			Value:     float64(worker.LastStatus.MaxThreads),
+			Type:      domain.MetricTypeGauge,
+			Unit:      "threads",
+			Timestamp: timestamp,
+			Labels:    labels,
+		})
+
+		if worker.LastStatus.BusyThreads != nil {
+			_ = collection.Add(domain.Metric{
+				Name:      "workers.busy_threads",
+				Value:     float64(*worker.LastStatus.BusyThreads),
+				Type:      domain.MetricTypeGauge,
+				Unit:      "threads",
+				Timestamp: timestamp,
+				Labels:    labels,
+			})
+		}
+
+		if worker.LastStatus.BacklogMax != nil {
+			_ = collection.Add(domain.Metric{
+				Name:      "workers.peak_backlog",
+				Value:     float64(*worker.LastStatus.BacklogMax),
+				Type:      domain.MetricTypeGauge,
+				Unit:      "requests",
+				Timestamp: timestamp,
+				Labels:    labels,
+			})
+		}
+
+		if worker.LastStatus.ReactorMax != nil {
+			_ = collection.Add(domain.Metric{
+				Name:      "workers.peak_reactor",
+				Value:     float64(*worker.LastStatus.ReactorMax),
+				Type:      domain.MetricTypeGauge,
+				Unit:      "requests",
+				Timestamp: timestamp,
+				Labels:    labels,
+			})
+		}
+
+		if worker.LastStatus.RequestsCount != nil {
+			_ = collection.Add(domain.Metric{
+				Name:      "workers.requests_count",
+				Value:     float64(*worker.LastStatus.RequestsCount),
+				Type:      domain.MetricTypeCounter,
+				Unit:      "requests",
+				Timestamp: timestamp,
+				Labels:    labels,
+			})
+		}
+	}
+}
+
+// addCheckinMetrics adds worker checkin age metrics; a worker whose last
+// checkin is older than the threshold is counted as stale
+func addCheckinMetrics(collection *domain.MetricCollection, workers []infrastructure.WorkerStatus, options Options, timestamp time.Time) {
+	var maxAge float64
+	var stale, checkedIn int
+	for _, worker := range workers {
+		checkin, ok := worker.CheckinTime()
+		if !ok {
+			continue
+		}
+		checkedIn++
+
+		age := max(timestamp.Sub(checkin).Seconds(), 0)
+		maxAge = max(maxAge, age)
+		if age > options.staleThreshold().Seconds() {
+			stale++
+		}
+
+		if options.PerWorker {
+			_ = collection.Add(domain.Metric{
+				Name:      "workers.checkin_age",
+				Value:     age,
+				Type:      domain.MetricTypeGauge,
+				Unit:      "seconds",
+				Timestamp: timestamp,
+				Labels: map[string]string{
+					domain.LabelWorker: strconv.Itoa(worker.Index),
+					domain.LabelPID:    strconv.Itoa(worker.PID),
+				},
+			})
+		}
+	}
+
+	if checkedIn == 0 {
+		return
+	}
+
+	_ = collection.Add(domain.Metric{
+		Name:      "workers.max_checkin_age",
+		Value:     maxAge,
+		Type:      domain.MetricTypeGauge,
+		Unit:      "seconds",
+		Timestamp: timestamp,
+	})
+
+	_ = collection.Add(domain.Metric{
+		Name:      "workers.stale",
+		Value:     float64(stale),
+		Type:      domain.MetricTypeGauge,
+		Unit:      "count",
+		Timestamp: timestamp,
+	})
+}
@@ -0,0 +1,221 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProcessMemory holds the memory usage of a process in bytes
+type ProcessMemory struct {
+	RSS  uint64
+	PSS  uint64
+	USS  uint64
+	Swap uint64
+	// HasRollup is false when smaps_rollup was unavailable and only RSS and
+	// swap could be read from /proc/<pid>/status
+	HasRollup bool
+}
+
+// UserHZ is the kernel's USER_HZ, the unit of CPU times in /proc/<pid>/stat;
+// it is 100 on every mainstream Linux architecture
+const UserHZ = 100
+
+// ProcessCPU holds the cumulative CPU time of a process in clock ticks
+type ProcessCPU struct {
+	User   uint64 `json:"user"`
+	System uint64 `json:"system"`
+}
+
+// ProcReader reads process information from procfs
+type ProcReader struct {
+	root string
+}
+
+// NewProcReader creates a new procfs reader rooted at root (usually /proc)
+func NewProcReader(root string) *ProcReader {
+	return &ProcReader{
+		root: root,
+	}
+}
+
+// Memory reads the memory usage of pid
+func (r *ProcReader) Memory(pid int) (ProcessMemory, error) {
+	fields, err := r.readKB(pid, "smaps_rollup")
+	if err == nil {
+		return ProcessMemory{
+			RSS:       fields["Rss"],
+			PSS:       fields["Pss"],
+			USS:       fields["Private_Clean"] + fields["Private_Dirty"],
+			Swap:      fields["Swap"],
+			HasRollup: true,
+		}, nil
+	}
+
+	// smaps_rollup needs Linux 4.14+
+	fields, err = r.readKB(pid, "status")
+	if err != nil {
+		return ProcessMemory{}, err
+	}
+	return ProcessMemory{
+		RSS:  fields["VmRSS"],
+		Swap: fields["VmSwap"],
+	}, nil
+}
+
+// CPU reads the cumulative user and system CPU time of pid
+func (r *ProcReader) CPU(pid int) (ProcessCPU, error) {
+	data, err := os.ReadFile(filepath.Join(r.root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return ProcessCPU{}, fmt.Errorf("reading stat of pid %d: %w", pid, err)
+	}
+
+	// comm is in parentheses and may itself contain spaces or parentheses
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return ProcessCPU{}, fmt.Errorf("malformed stat of pid %d", pid)
+	}
+	// Fields after comm start at field 3 (state); utime and stime are 14 and 15
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 13 {
+		return ProcessCPU{}, fmt.Errorf("malformed stat of pid %d", pid)
+	}
+
+	user, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return ProcessCPU{}, fmt.Errorf("parsing utime of pid %d: %w", pid, err)
+	}
+	system, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return ProcessCPU{}, fmt.Errorf("parsing stime of pid %d: %w", pid, err)
+	}
+
+	return ProcessCPU{User: user, System: system}, nil
+}
+
+// ParentPID returns the parent pid of pid
+func (r *ProcReader) ParentPID(pid int) (int, error) {
+	fields, err := r.readKB(pid, "status")
+	if err != nil {
+		return 0, err
+	}
+	ppid, ok := fields["PPid"]
+	if !ok {
+		return 0, fmt.Errorf("no PPid in /proc/%d/status", pid)
+	}
+	return int(ppid), nil
+}
+
+// HostInfo holds host-wide facts used to put Puma's footprint in proportion
+type HostInfo struct {
+	// CPUs is the number of online CPUs listed in /proc/stat; cgroup CPU
+	// limits of containers are not taken into account
+	CPUs     int
+	LoadAvg1 float64
+	// MemTotal and MemAvailable are in bytes
+	MemTotal     uint64
+	MemAvailable uint64
+}
+
+// Host reads the CPU count, load average and memory of the host
+func (r *ProcReader) Host() (HostInfo, error) {
+	var info HostInfo
+
+	stat, err := os.ReadFile(filepath.Join(r.root, "stat"))
+	if err != nil {
+		return HostInfo{}, fmt.Errorf("reading stat: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(stat))
+	for scanner.Scan() {
+		// The aggregate line is "cpu ", each CPU has a "cpuN " line
+		field, _, _ := strings.Cut(scanner.Text(), " ")
+		if n, found := strings.CutPrefix(field, "cpu"); found && n != "" {
+			info.CPUs++
+		}
+	}
+	if info.CPUs == 0 {
+		return HostInfo{}, fmt.Errorf("no CPUs listed in stat")
+	}
+
+	loadavg, err := os.ReadFile(filepath.Join(r.root, "loadavg"))
+	if err != nil {
+		return HostInfo{}, fmt.Errorf("reading loadavg: %w", err)
+	}
+	fields := strings.Fields(string(loadavg))
+	if len(fields) == 0 {
+		return HostInfo{}, fmt.Errorf("malformed loadavg")
+	}
+	if info.LoadAvg1, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return HostInfo{}, fmt.Errorf("parsing loadavg: %w", err)
+	}
+
+	meminfo, err := readKBFile(filepath.Join(r.root, "meminfo"))
+	if err != nil {
+		return HostInfo{}, fmt.Errorf("reading meminfo: %w", err)
+	}
+	info.MemTotal = meminfo["MemTotal"]
+	info.MemAvailable = meminfo["MemAvailable"]
+	if info.MemTotal == 0 {
+		return HostInfo{}, fmt.Errorf("no MemTotal in meminfo")
+	}
+
+	return info, nil
+}
+
+// readKB parses "Key: value [kB]" lines from /proc/<pid>/<name>
+func (r *ProcReader) readKB(pid int, name string) (map[string]uint64, error) {
+	fields, err := readKBFile(filepath.Join(r.root, strconv.Itoa(pid), name))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s of pid %d: %w", name, pid, err)
+	}
+	return fields, nil
+}
+
+// readKBFile parses "Key: value [kB]" lines, returning values scaled to
+// bytes when they carry a kB unit
+func readKBFile(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, rest, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		parts := strings.Fields(rest)
+		if len(parts) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(parts) > 1 && parts[1] == "kB" {
+			value *= 1024
+		}
+		fields[key] = value
+	}
+
+	return fields, scanner.Err()
+}
+
+// ReadPIDFile reads a pid from a pid file such as Puma's pidfile
+func ReadPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("reading pid file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("parsing pid file %s: %w", path, err)
+	}
+	return pid, nil
+}
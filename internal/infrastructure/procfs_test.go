@@ -0,0 +1,121 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestProcReader(t *testing.T) {
+	root := t.TempDir()
+	writeProcFile(t, root, 100, "smaps_rollup", `55d0c8a3e000-7ffd5a5f1000 ---p 00000000 00:00 0                          [rollup]
+Rss:              204800 kB
+Pss:              102400 kB
+Shared_Clean:      40960 kB
+Shared_Dirty:      61440 kB
+Private_Clean:     20480 kB
+Private_Dirty:     81920 kB
+Swap:               1024 kB
+`)
+	writeProcFile(t, root, 200, "status", `Name:	ruby
+PPid:	100
+VmRSS:	  51200 kB
+VmSwap:	      0 kB
+`)
+
+	writeProcFile(t, root, 100, "stat", "100 (puma 6.4.2 (app)) S 1 100 100 0 -1 4194560 5000 0 0 0 1234 567 0 0 20 0 12 0 100 0 0\n")
+
+	reader := infrastructure.NewProcReader(root)
+
+	t.Run("smaps_rollup", func(t *testing.T) {
+		mem, err := reader.Memory(100)
+		if err != nil {
+			t.Fatalf("Memory() error = %v", err)
+		}
+		want := infrastructure.ProcessMemory{
+			RSS:       204800 * 1024,
+			PSS:       102400 * 1024,
+			USS:       (20480 + 81920) * 1024,
+			Swap:      1024 * 1024,
+			HasRollup: true,
+		}
+		if mem != want {
+			t.Errorf("Memory() = %+v, want %+v", mem, want)
+		}
+	})
+
+	t.Run("status fallback", func(t *testing.T) {
+		mem, err := reader.Memory(200)
+		if err != nil {
+			t.Fatalf("Memory() error = %v", err)
+		}
+		if mem.RSS != 51200*1024 || mem.HasRollup {
+			t.Errorf("Memory() = %+v", mem)
+		}
+	})
+
+	t.Run("cpu", func(t *testing.T) {
+		cpu, err := reader.CPU(100)
+		if err != nil {
+			t.Fatalf("CPU() error = %v", err)
+		}
+		if cpu.User != 1234 || cpu.System != 567 {
+			t.Errorf("CPU() = %+v, want user 1234 system 567", cpu)
+		}
+	})
+
+	t.Run("parent pid", func(t *testing.T) {
+		ppid, err := reader.ParentPID(200)
+		if err != nil || ppid != 100 {
+			t.Errorf("ParentPID() = %d, %v, want 100", ppid, err)
+		}
+	})
+
+	t.Run("missing process", func(t *testing.T) {
+		if _, err := reader.Memory(300); err == nil {
+			t.Error("Memory() should fail for a missing process")
+		}
+	})
+}
+
+func TestProcReader_Host(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"stat":    "cpu  100 0 50 1000 0 0 0 0 0 0\ncpu0 50 0 25 500 0 0 0 0 0 0\ncpu1 50 0 25 500 0 0 0 0 0 0\nintr 12345\nctxt 678\n",
+		"loadavg": "1.50 0.75 0.25 2/345 6789\n",
+		"meminfo": "MemTotal:        8192000 kB\nMemFree:         1024000 kB\nMemAvailable:    4096000 kB\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	host, err := infrastructure.NewProcReader(root).Host()
+	if err != nil {
+		t.Fatalf("Host() error = %v", err)
+	}
+	want := infrastructure.HostInfo{
+		CPUs:         2,
+		LoadAvg1:     1.5,
+		MemTotal:     8192000 * 1024,
+		MemAvailable: 4096000 * 1024,
+	}
+	if host != want {
+		t.Errorf("Host() = %+v, want %+v", host, want)
+	}
+}
+
+func writeProcFile(t *testing.T, root string, pid int, name, content string) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 type PumaClient interface {
 	GetStats(ctx context.Context) (*PumaStats, error)
 	GetGCStats(ctx context.Context) (map[string]interface{}, error)
+	GetThreadBacktraces(ctx context.Context) ([]ThreadBacktrace, error)
 }
 
 // PumaStats represents Puma statistics
@@ -28,6 +29,54 @@ type PumaStats struct {
 	// Puma 6.x fields
 	RequestsCount *int64 `json:"requests_count,omitempty"`
 	Uptime        *int   `json:"uptime,omitempty"`
+	// BusyThreads is reported in single mode by Puma 6.6 and later
+	BusyThreads *int `json:"busy_threads,omitempty"`
+	// Puma 7 fields, reported in single mode
+	BacklogMax *int `json:"backlog_max,omitempty"`
+	ReactorMax *int `json:"reactor_max,omitempty"`
+	// StartedAt is when the server booted, reported by Puma 5 and later
+	StartedAt string `json:"started_at,omitempty"`
+	// Versions is reported by recent Puma releases
+	Versions *Versions `json:"versions,omitempty"`
+
+	// Raw is the /stats response as received, including fields this
+	// struct does not model yet
+	Raw json.RawMessage `json:"-"`
+}
+
+// Mode is how Puma runs: a single process serving requests, or a master
+// process with workers (clustered mode)
+type Mode string
+
+const (
+	ModeSingle  Mode = "single"
+	ModeCluster Mode = "cluster"
+)
+
+// Mode returns the mode the stats describe: clustered when they include
+// worker_status (empty while the workers boot), or a worker count without
+// the top-level thread pool fields of single mode
+func (s *PumaStats) Mode() Mode {
+	if s.WorkerStatus != nil {
+		return ModeCluster
+	}
+	if s.Workers > 0 && s.Backlog == nil && s.Running == nil {
+		return ModeCluster
+	}
+	return ModeSingle
+}
+
+// Versions are the Puma and Ruby versions a server reports in /stats
+type Versions struct {
+	Puma string       `json:"puma"`
+	Ruby RubyVersions `json:"ruby"`
+}
+
+// RubyVersions describes the Ruby running Puma
+type RubyVersions struct {
+	Engine     string `json:"engine"`
+	Version    string `json:"version"`
+	Patchlevel int    `json:"patchlevel"`
 }
 
 // WorkerStatus represents individual worker status
@@ -37,60 +86,121 @@ type WorkerStatus struct {
 	Phase       int        `json:"phase"`
 	Booted      bool       `json:"booted"`
 	LastCheckin string     `json:"last_checkin"`
+	StartedAt   string     `json:"started_at,omitempty"`
 	LastStatus  LastStatus `json:"last_status"`
 }
 
+// CheckinTime parses LastCheckin, which Puma reports as an ISO 8601 UTC time
+func (w WorkerStatus) CheckinTime() (time.Time, bool) {
+	return parseStatsTime(w.LastCheckin)
+}
+
+// StartTime parses StartedAt, reported by Puma 5 and later
+func (w WorkerStatus) StartTime() (time.Time, bool) {
+	return parseStatsTime(w.StartedAt)
+}
+
+// parseStatsTime parses an ISO 8601 time from the stats payload
+func parseStatsTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
 // LastStatus represents worker's last status
 type LastStatus struct {
-	Backlog      int `json:"backlog"`
-	Running      int `json:"running"`
-	PoolCapacity int `json:"pool_capacity"`
-	MaxThreads   int `json:"max_threads"`
+	Backlog       int    `json:"backlog"`
+	Running       int    `json:"running"`
+	PoolCapacity  int    `json:"pool_capacity"`
+	MaxThreads    int    `json:"max_threads"`
+	RequestsCount *int64 `json:"requests_count,omitempty"`
+	// BusyThreads is reported by Puma 6.6 and later
+	BusyThreads *int `json:"busy_threads,omitempty"`
+	// BacklogMax and ReactorMax are the peak backlog and reactor queue
+	// sizes since the previous status, reported by Puma 7 and later
+	BacklogMax *int `json:"backlog_max,omitempty"`
+	ReactorMax *int `json:"reactor_max,omitempty"`
+}
+
+// ThreadBacktrace is a thread of the Puma process and its backtrace, as
+// reported by /thread-backtraces
+type ThreadBacktrace struct {
+	Name      string   `json:"name"`
+	Backtrace []string `json:"backtrace"`
+}
+
+// Transport performs raw GET requests against the control server. Get
+// must return once ctx is done, without leaving I/O in flight.
+type Transport interface {
+	Get(ctx context.Context, path string) ([]byte, error)
+}
+
+// ClientConfig holds the settings needed to reach the control server
+type ClientConfig struct {
+	// SocketPath selects the Unix socket transport when set
+	SocketPath string
+	// BaseURL is used for the TCP transport, e.g. http://127.0.0.1:9293
+	BaseURL string
+	// Token is the control server auth_token, sent as ?token= on every request
+	Token   string
+	Timeout time.Duration
+	// Retry is applied to /stats requests; the zero value makes a single
+	// attempt
+	Retry RetryPolicy
 }
 
 // DefaultPumaClient is the default implementation of PumaClient
 type DefaultPumaClient struct {
-	client        *UnixSocketClient
-	retryCount    int
-	retryInterval time.Duration
+	client Transport
+	retry  RetryPolicy
 }
 
-// NewPumaClient creates a new Puma client
-func NewPumaClient(socketPath string, timeout time.Duration) PumaClient {
+// NewPumaClient creates a new Puma client, using the Unix socket transport
+// when a socket path is configured and TCP otherwise
+func NewPumaClient(config ClientConfig) PumaClient {
+	var transport Transport
+	if config.SocketPath != "" {
+		transport = NewUnixSocketClient(config.SocketPath, config.Token, config.Timeout)
+	} else {
+		transport = NewTCPClient(config.BaseURL, config.Token, config.Timeout)
+	}
+
 	return &DefaultPumaClient{
-		client:        NewUnixSocketClient(socketPath, timeout),
-		retryCount:    3,
-		retryInterval: 1 * time.Second,
+		client: transport,
+		retry:  config.Retry,
 	}
 }
 
-// GetStats retrieves Puma statistics with retry logic
+// GetStats retrieves Puma statistics, retrying as the client's retry
+// policy allows
 func (c *DefaultPumaClient) GetStats(ctx context.Context) (*PumaStats, error) {
-	var lastErr error
-
-	for i := range c.retryCount + 1 {
-		if i > 0 {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(c.retryInterval):
-			}
-		}
-
-		stats, err := c.fetchStats()
-		if err == nil {
-			return stats, nil
-		}
-
-		lastErr = err
+	var stats *PumaStats
+	err := c.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		stats, err = c.fetchStats(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return stats, nil
+}
 
-	return nil, fmt.Errorf("failed after %d attempts: %w", c.retryCount+1, lastErr)
+// CloseIdleConnections closes the transport's kept-alive connections
+func (c *DefaultPumaClient) CloseIdleConnections() {
+	if closer, ok := c.client.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
 }
 
 // fetchStats performs the actual fetch operation
-func (c *DefaultPumaClient) fetchStats() (*PumaStats, error) {
-	body, err := c.client.Get("/stats")
+func (c *DefaultPumaClient) fetchStats(ctx context.Context) (*PumaStats, error) {
+	body, err := c.client.Get(ctx, "/stats")
 	if err != nil {
 		return nil, err
 	}
@@ -99,13 +209,14 @@ func (c *DefaultPumaClient) fetchStats() (*PumaStats, error) {
 	if err := json.Unmarshal(body, &stats); err != nil {
 		return nil, fmt.Errorf("parsing stats JSON: %w", err)
 	}
+	stats.Raw = body
 
 	return &stats, nil
 }
 
 // GetGCStats retrieves GC statistics
 func (c *DefaultPumaClient) GetGCStats(ctx context.Context) (map[string]interface{}, error) {
-	body, err := c.client.Get("/gc-stats")
+	body, err := c.client.Get(ctx, "/gc-stats")
 	if err != nil {
 		return nil, err
 	}
@@ -117,3 +228,19 @@ func (c *DefaultPumaClient) GetGCStats(ctx context.Context) (map[string]interfac
 
 	return gcStats, nil
 }
+
+// GetThreadBacktraces retrieves the backtraces of the threads in the Puma
+// process serving the control app
+func (c *DefaultPumaClient) GetThreadBacktraces(ctx context.Context) ([]ThreadBacktrace, error) {
+	body, err := c.client.Get(ctx, string(EndpointThreadBacktraces))
+	if err != nil {
+		return nil, err
+	}
+
+	var backtraces []ThreadBacktrace
+	if err := json.Unmarshal(body, &backtraces); err != nil {
+		return nil, fmt.Errorf("parsing thread-backtraces JSON: %w", err)
+	}
+
+	return backtraces, nil
+}
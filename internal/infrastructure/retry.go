@@ -0,0 +1,156 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries failed control server requests with exponential
+// backoff and jitter. Only errors IsRetryable accepts are retried, and no
+// retry starts after MaxElapsed or past ctx's deadline, so a dead socket
+// fails within a known time.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// InitialInterval is the wait before the first retry; each further
+	// wait is Multiplier times longer, up to MaxInterval
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Jitter randomizes each wait by up to ±Jitter of it (0 to 1), so that
+	// plugins restarted together don't retry in lockstep
+	Jitter float64
+	// MaxElapsed bounds the time from the first attempt to the start of
+	// the last one; 0 means no bound beyond ctx
+	MaxElapsed time.Duration
+	// OnRetry, if set, is called before waiting for each retry
+	OnRetry func(retry int, wait time.Duration, err error)
+}
+
+// NoRetry makes a single attempt
+var NoRetry = RetryPolicy{}
+
+// Backoff returns the wait before the given retry (1 for the first),
+// without jitter
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	wait := float64(p.InitialInterval)
+	for range retry - 1 {
+		wait *= multiplier
+		if p.MaxInterval > 0 && wait >= float64(p.MaxInterval) {
+			return p.MaxInterval
+		}
+	}
+	return time.Duration(wait)
+}
+
+// jittered randomizes wait by up to ±Jitter of it
+func (p RetryPolicy) jittered(wait time.Duration) time.Duration {
+	if p.Jitter <= 0 || wait <= 0 {
+		return wait
+	}
+	jitter := min(p.Jitter, 1)
+	return time.Duration(float64(wait) * (1 - jitter + 2*jitter*rand.Float64()))
+}
+
+// Do calls op until it succeeds, fails with an error that isn't retryable,
+// or the retries, MaxElapsed or ctx run out. The error of the last attempt
+// is returned, noting the attempt count when there was more than one.
+func (p RetryPolicy) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	start := time.Now()
+	attempts := 0
+
+	for {
+		err := op(ctx)
+		attempts++
+		if err == nil {
+			return nil
+		}
+		if !IsRetryable(err) || attempts > p.MaxRetries {
+			return attemptsError(attempts, err)
+		}
+
+		wait := p.jittered(p.Backoff(attempts))
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return attemptsError(attempts, err)
+		}
+		// A retry that would start after the deadline cannot finish
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return attemptsError(attempts, err)
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(attempts, wait, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attemptsError(attempts, err)
+		case <-timer.C:
+		}
+	}
+}
+
+func attemptsError(attempts int, err error) error {
+	if attempts == 1 {
+		return err
+	}
+	return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+}
+
+// StatusError is a control server response with an unexpected status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// IsRetryable reports whether a request that failed with err may succeed
+// when repeated: the socket missing or refusing connections while Puma
+// restarts, a dropped connection, a timeout or a 5xx response. A rejected
+// token, other status codes, a response that doesn't parse and a cancelled
+// request are final.
+func IsRetryable(err error) bool {
+	var statusErr *StatusError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var netErr net.Error
+
+	switch {
+	case err == nil,
+		errors.Is(err, ErrAuthentication),
+		errors.Is(err, context.Canceled),
+		errors.As(err, &syntaxErr),
+		errors.As(err, &typeErr):
+		return false
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode >= 500
+	case errors.Is(err, syscall.ENOENT),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,190 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := infrastructure.RetryPolicy{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     500 * time.Millisecond,
+		Multiplier:      2,
+	}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 500 * time.Millisecond},
+		{10, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.retry); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.retry, got, tt.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"missing socket", fmt.Errorf("connecting: %w", syscall.ENOENT), true},
+		{"connection refused", fmt.Errorf("connecting: %w", syscall.ECONNREFUSED), true},
+		{"connection reset", fmt.Errorf("reading: %w", syscall.ECONNRESET), true},
+		{"truncated response", fmt.Errorf("reading: %w", io.ErrUnexpectedEOF), true},
+		{"request timeout", fmt.Errorf("requesting: %w", context.DeadlineExceeded), true},
+		{"service unavailable", &infrastructure.StatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"forbidden", fmt.Errorf("%w: 403", infrastructure.ErrAuthentication), false},
+		{"not found", &infrastructure.StatusError{StatusCode: http.StatusNotFound}, false},
+		{"parse error", fmt.Errorf("parsing stats JSON: %w", &json.SyntaxError{}), false},
+		{"cancelled", fmt.Errorf("requesting: %w", context.Canceled), false},
+		{"unknown", errors.New("something else"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := infrastructure.IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	refused := fmt.Errorf("connecting: %w", syscall.ECONNREFUSED)
+
+	tests := []struct {
+		name         string
+		policy       infrastructure.RetryPolicy
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "succeeds after retries",
+			policy:       infrastructure.RetryPolicy{MaxRetries: 3, InitialInterval: time.Millisecond},
+			errs:         []error{refused, refused, nil},
+			wantAttempts: 3,
+		},
+		{
+			name:         "retries run out",
+			policy:       infrastructure.RetryPolicy{MaxRetries: 2, InitialInterval: time.Millisecond},
+			errs:         []error{refused, refused, refused, nil},
+			wantAttempts: 3,
+			wantErr:      syscall.ECONNREFUSED,
+		},
+		{
+			name:         "not retryable",
+			policy:       infrastructure.RetryPolicy{MaxRetries: 3, InitialInterval: time.Millisecond},
+			errs:         []error{infrastructure.ErrAuthentication, nil},
+			wantAttempts: 1,
+			wantErr:      infrastructure.ErrAuthentication,
+		},
+		{
+			name: "max elapsed",
+			policy: infrastructure.RetryPolicy{
+				MaxRetries:      5,
+				InitialInterval: 20 * time.Millisecond,
+				Multiplier:      2,
+				MaxElapsed:      50 * time.Millisecond,
+			},
+			// Waits of 20ms and 40ms would start the third attempt at 60ms
+			errs:         []error{refused, refused, refused, nil},
+			wantAttempts: 2,
+			wantErr:      syscall.ECONNREFUSED,
+		},
+		{
+			name:         "no retry",
+			policy:       infrastructure.NoRetry,
+			errs:         []error{refused, nil},
+			wantAttempts: 1,
+			wantErr:      syscall.ECONNREFUSED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := tt.policy.Do(t.Context(), func(ctx context.Context) error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Do() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_DoStopsBeforeDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	policy := infrastructure.RetryPolicy{MaxRetries: 3, InitialInterval: time.Second}
+	attempts := 0
+	start := time.Now()
+	err := policy.Do(ctx, func(ctx context.Context) error {
+		attempts++
+		return syscall.ENOENT
+	})
+
+	// A retry after a second could not start before the deadline
+	if attempts != 1 || !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Do() = %v after %d attempts, want ENOENT after 1", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Do() took %v, want it to return without waiting", elapsed)
+	}
+}
+
+func TestPumaClient_GetStatsRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"workers":2}`)
+	}))
+	defer server.Close()
+
+	client := infrastructure.NewPumaClient(infrastructure.ClientConfig{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		Retry:   infrastructure.RetryPolicy{MaxRetries: 3, InitialInterval: time.Millisecond},
+	})
+
+	stats, err := client.GetStats(t.Context())
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.Workers != 2 || attempts != 3 {
+		t.Errorf("GetStats() = %+v after %d attempts, want 2 workers after 3", stats, attempts)
+	}
+}
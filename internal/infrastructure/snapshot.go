@@ -0,0 +1,131 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Endpoint is a control server endpoint a snapshot can include
+type Endpoint string
+
+const (
+	EndpointStats            Endpoint = "/stats"
+	EndpointGCStats          Endpoint = "/gc-stats"
+	EndpointThreadBacktraces Endpoint = "/thread-backtraces"
+)
+
+// EndpointResult is the outcome of fetching one endpoint
+type EndpointResult struct {
+	Err      error
+	Duration time.Duration
+}
+
+// Snapshot is what the control server reported for a set of endpoints
+// fetched together. Only the fields of endpoints that succeeded are set.
+type Snapshot struct {
+	Stats            *PumaStats
+	GCStats          map[string]interface{}
+	ThreadBacktraces []ThreadBacktrace
+
+	// Results has an entry for every endpoint that was requested
+	Results map[Endpoint]EndpointResult
+}
+
+// OK reports whether endpoint was requested and fetched successfully
+func (s *Snapshot) OK(endpoint Endpoint) bool {
+	result, ok := s.Results[endpoint]
+	return ok && result.Err == nil
+}
+
+// Err returns the error of fetching endpoint, or nil when it succeeded or
+// wasn't requested
+func (s *Snapshot) Err(endpoint Endpoint) error {
+	return s.Results[endpoint].Err
+}
+
+// secondaryGrace is how long FetchSnapshot waits for the other endpoints
+// once /stats has finished, so that a slow /gc-stats or /thread-backtraces
+// delays the core metrics by no more than this
+const secondaryGrace = 500 * time.Millisecond
+
+// ErrSkipped is the error of an endpoint that was still pending
+// secondaryGrace after /stats finished
+var ErrSkipped = errors.New("skipped: still pending after /stats finished")
+
+// FetchSnapshot fetches endpoints, which must be distinct, concurrently and
+// bounded by ctx. It waits for /stats, if requested, and then for the other
+// endpoints at most secondaryGrace longer, cancelling and skipping any still
+// pending. A failed endpoint does not affect the others, so callers use
+// whichever succeeded.
+func FetchSnapshot(ctx context.Context, client PumaClient, endpoints ...Endpoint) *Snapshot {
+	snapshot := &Snapshot{Results: make(map[Endpoint]EndpointResult, len(endpoints))}
+	start := time.Now()
+
+	// Cancels the requests of skipped endpoints on return
+	secondaryCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type fetched struct {
+		endpoint Endpoint
+		store    func(*Snapshot)
+		result   EndpointResult
+	}
+	// Buffered so that skipped fetches finish without a receiver
+	done := make(chan fetched, len(endpoints))
+	for _, endpoint := range endpoints {
+		fetchCtx := secondaryCtx
+		if endpoint == EndpointStats {
+			fetchCtx = ctx
+		}
+		go func() {
+			fetchStart := time.Now()
+			store, err := fetchEndpoint(fetchCtx, client, endpoint)
+			done <- fetched{endpoint, store, EndpointResult{Err: err, Duration: time.Since(fetchStart)}}
+		}()
+	}
+
+	var grace <-chan time.Time
+	for range endpoints {
+		select {
+		case f := <-done:
+			snapshot.Results[f.endpoint] = f.result
+			if f.result.Err == nil {
+				f.store(snapshot)
+			}
+			if f.endpoint == EndpointStats {
+				timer := time.NewTimer(secondaryGrace)
+				defer timer.Stop()
+				grace = timer.C
+			}
+		case <-grace:
+			for _, endpoint := range endpoints {
+				if _, finished := snapshot.Results[endpoint]; !finished {
+					snapshot.Results[endpoint] = EndpointResult{Err: ErrSkipped, Duration: time.Since(start)}
+				}
+			}
+			return snapshot
+		}
+	}
+
+	return snapshot
+}
+
+// fetchEndpoint fetches one endpoint and returns a function storing the
+// response in its field of a snapshot
+func fetchEndpoint(ctx context.Context, client PumaClient, endpoint Endpoint) (func(*Snapshot), error) {
+	switch endpoint {
+	case EndpointStats:
+		stats, err := client.GetStats(ctx)
+		return func(s *Snapshot) { s.Stats = stats }, err
+	case EndpointGCStats:
+		gcStats, err := client.GetGCStats(ctx)
+		return func(s *Snapshot) { s.GCStats = gcStats }, err
+	case EndpointThreadBacktraces:
+		backtraces, err := client.GetThreadBacktraces(ctx)
+		return func(s *Snapshot) { s.ThreadBacktraces = backtraces }, err
+	default:
+		return nil, fmt.Errorf("unknown endpoint %s", endpoint)
+	}
+}
@@ -0,0 +1,81 @@
+package infrastructure_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestFetchSnapshot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stats":
+			_, _ = io.WriteString(w, `{"workers":2}`)
+		case "/gc-stats":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/thread-backtraces":
+			time.Sleep(200 * time.Millisecond)
+			_, _ = io.WriteString(w, `[{"name":"Thread: TID-1 puma threadpool 001","backtrace":["a.rb:1:in 'sleep'"]}]`)
+		}
+	}))
+	defer server.Close()
+
+	client := infrastructure.NewPumaClient(infrastructure.ClientConfig{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	start := time.Now()
+	snapshot := infrastructure.FetchSnapshot(t.Context(), client,
+		infrastructure.EndpointStats, infrastructure.EndpointGCStats, infrastructure.EndpointThreadBacktraces)
+
+	// The slow endpoints are fetched at the same time
+	if elapsed := time.Since(start); elapsed > 350*time.Millisecond {
+		t.Errorf("FetchSnapshot() took %v, want the endpoints fetched concurrently", elapsed)
+	}
+
+	if !snapshot.OK(infrastructure.EndpointStats) || snapshot.Stats.Workers != 2 {
+		t.Errorf("stats = %+v, %v", snapshot.Stats, snapshot.Err(infrastructure.EndpointStats))
+	}
+
+	var statusErr *infrastructure.StatusError
+	if snapshot.OK(infrastructure.EndpointGCStats) || !errors.As(snapshot.Err(infrastructure.EndpointGCStats), &statusErr) {
+		t.Errorf("gc-stats error = %v, want a *StatusError", snapshot.Err(infrastructure.EndpointGCStats))
+	}
+	if snapshot.GCStats != nil {
+		t.Errorf("GCStats = %v, want nil after a failure", snapshot.GCStats)
+	}
+
+	if !snapshot.OK(infrastructure.EndpointThreadBacktraces) || len(snapshot.ThreadBacktraces) != 1 ||
+		snapshot.ThreadBacktraces[0].Name != "Thread: TID-1 puma threadpool 001" {
+		t.Errorf("thread backtraces = %+v, %v", snapshot.ThreadBacktraces, snapshot.Err(infrastructure.EndpointThreadBacktraces))
+	}
+
+	if len(snapshot.Results) != 3 {
+		t.Errorf("Results = %v, want an entry per endpoint", snapshot.Results)
+	}
+}
+
+func TestFetchSnapshot_SkipsPendingAfterStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gc-stats" {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = io.WriteString(w, `{"workers":2}`)
+	}))
+	defer server.Close()
+
+	client := infrastructure.NewPumaClient(infrastructure.ClientConfig{BaseURL: server.URL, Timeout: 5 * time.Second})
+	snapshot := infrastructure.FetchSnapshot(t.Context(), client, infrastructure.EndpointStats, infrastructure.EndpointGCStats)
+
+	if !snapshot.OK(infrastructure.EndpointStats) {
+		t.Errorf("stats error = %v", snapshot.Err(infrastructure.EndpointStats))
+	}
+	if err := snapshot.Err(infrastructure.EndpointGCStats); !errors.Is(err, infrastructure.ErrSkipped) {
+		t.Errorf("gc-stats error = %v, want ErrSkipped", err)
+	}
+}
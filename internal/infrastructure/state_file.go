@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PumaState represents the contents of Puma's state file (state_path)
+type PumaState struct {
+	PID              int    `yaml:"pid"`
+	ControlURL       string `yaml:"control_url"`
+	ControlAuthToken string `yaml:"control_auth_token"`
+}
+
+// ControlEndpoint describes where the control server listens
+type ControlEndpoint struct {
+	SocketPath string
+	Scheme     string
+	Host       string
+	Port       string
+}
+
+// ReadStateFile reads and parses a Puma state file
+func ReadStateFile(path string) (*PumaState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+
+	var state PumaState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing state file %s: %w", path, err)
+	}
+
+	if state.ControlURL == "" {
+		return nil, fmt.Errorf("state file %s has no control_url; is activate_control_app enabled?", path)
+	}
+
+	return &state, nil
+}
+
+// Equal reports whether two states point at the same server with the same token
+func (s *PumaState) Equal(other *PumaState) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return *s == *other
+}
+
+// ControlEndpoint parses control_url (unix://, tcp:// or ssl://)
+func (s *PumaState) ControlEndpoint() (ControlEndpoint, error) {
+	u, err := url.Parse(s.ControlURL)
+	if err != nil {
+		return ControlEndpoint{}, fmt.Errorf("parsing control_url %q: %w", s.ControlURL, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		// unix:///path and unix://@abstract are both valid in Puma
+		return ControlEndpoint{SocketPath: u.Host + u.Path}, nil
+	case "tcp", "ssl":
+		scheme := "http"
+		if u.Scheme == "ssl" {
+			scheme = "https"
+		}
+		return ControlEndpoint{
+			Scheme: scheme,
+			Host:   u.Hostname(),
+			Port:   u.Port(),
+		}, nil
+	default:
+		return ControlEndpoint{}, fmt.Errorf("unsupported control_url scheme: %s", u.Scheme)
+	}
+}
@@ -0,0 +1,78 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestReadStateFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     infrastructure.ControlEndpoint
+		wantErr  bool
+		wantPID  int
+		wantAuth string
+	}{
+		{
+			name: "unix socket",
+			content: `---
+pid: 4321
+control_url: unix:///var/run/puma/pumactl.sock
+control_auth_token: 0123abcd
+running_from: "/app"
+version: 6.4.2
+`,
+			want:     infrastructure.ControlEndpoint{SocketPath: "/var/run/puma/pumactl.sock"},
+			wantPID:  4321,
+			wantAuth: "0123abcd",
+		},
+		{
+			name: "tcp",
+			content: `---
+pid: 99
+control_url: tcp://0.0.0.0:9293
+control_auth_token: secret
+`,
+			want:     infrastructure.ControlEndpoint{Scheme: "http", Host: "0.0.0.0", Port: "9293"},
+			wantPID:  99,
+			wantAuth: "secret",
+		},
+		{
+			name:    "no control url",
+			content: "---\npid: 1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "puma.state")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := infrastructure.ReadStateFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadStateFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if state.PID != tt.wantPID || state.ControlAuthToken != tt.wantAuth {
+				t.Errorf("ReadStateFile() = %+v", state)
+			}
+
+			got, err := state.ControlEndpoint()
+			if err != nil {
+				t.Fatalf("ControlEndpoint() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ControlEndpoint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
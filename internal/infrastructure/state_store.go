@@ -0,0 +1,64 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StateStore persists data between plugin runs as a JSON file
+type StateStore struct {
+	path string
+}
+
+// NewStateStore creates a new state store backed by path
+func NewStateStore(path string) *StateStore {
+	return &StateStore{
+		path: path,
+	}
+}
+
+// Load decodes the stored state into v; a missing file leaves v untouched
+func (s *StateStore) Load(v any) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading run state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parsing run state %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Save atomically replaces the stored state with v
+func (s *StateStore) Save(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding run state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing run state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing run state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing run state: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("writing run state: %w", err)
+	}
+	return nil
+}
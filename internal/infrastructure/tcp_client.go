@@ -0,0 +1,48 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TCPClient represents a client for the control server over TCP (http or https)
+type TCPClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewTCPClient creates a new TCP client for the given base URL
+func NewTCPClient(baseURL, token string, timeout time.Duration) *TCPClient {
+	return &TCPClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Get performs a GET request against the control server, bounded by both
+// the client timeout and ctx. Connections are kept alive between requests.
+func (c *TCPClient) Get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+withToken(path, c.token), nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", c.baseURL+path, err)
+	}
+
+	body, err := doGet(c.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s: %w", c.baseURL+path, err)
+	}
+	return body, nil
+}
+
+// CloseIdleConnections closes the kept-alive connections, e.g. before the
+// client is replaced
+func (c *TCPClient) CloseIdleConnections() {
+	c.httpClient.CloseIdleConnections()
+}
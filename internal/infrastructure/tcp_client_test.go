@@ -0,0 +1,61 @@
+package infrastructure_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestTCPClient_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/stats" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"workers":2}`))
+	}))
+	defer server.Close()
+
+	client := infrastructure.NewTCPClient(server.URL+"/", "secret", 5*time.Second)
+
+	t.Run("success", func(t *testing.T) {
+		body, err := client.Get(t.Context(), "/stats")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if string(body) != `{"workers":2}` {
+			t.Errorf("Get() = %s", body)
+		}
+	})
+
+	t.Run("non-200 status", func(t *testing.T) {
+		if _, err := client.Get(t.Context(), "/missing"); err == nil {
+			t.Error("Get() should fail on 404")
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		client := infrastructure.NewTCPClient(server.URL, "wrong", 5*time.Second)
+		_, err := client.Get(t.Context(), "/stats")
+		if !errors.Is(err, infrastructure.ErrAuthentication) {
+			t.Errorf("Get() error = %v, want ErrAuthentication", err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if _, err := client.Get(ctx, "/stats"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Get() error = %v, want context.Canceled", err)
+		}
+	})
+}
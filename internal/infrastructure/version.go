@@ -0,0 +1,78 @@
+package infrastructure
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Version is a Puma version. Versions guessed from the fields in /stats only
+// know the major release and are printed as e.g. 6.x.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// MajorOnly is set when Minor and Patch are unknown
+	MajorOnly bool
+}
+
+// versionPattern matches the first version number in a string, e.g. 6.6.1
+// in "puma 6.6.1 (ruby 3.3.0)", with an optional minor (or x) and patch
+var versionPattern = regexp.MustCompile(`(\d+)(?:\.(\d+|x))?(?:\.(\d+))?`)
+
+// ParseVersion parses the first version number in s, such as 7, 6.x, 6.6
+// or v6.6.1.pre1
+func ParseVersion(s string) (Version, error) {
+	matches := versionPattern.FindStringSubmatch(s)
+	if matches == nil {
+		return Version{}, fmt.Errorf("no version number in %q", s)
+	}
+
+	var version Version
+	version.Major, _ = strconv.Atoi(matches[1])
+	switch matches[2] {
+	case "", "x":
+		version.MajorOnly = true
+		return version, nil
+	default:
+		version.Minor, _ = strconv.Atoi(matches[2])
+	}
+	if matches[3] != "" {
+		version.Patch, _ = strconv.Atoi(matches[3])
+	}
+	return version, nil
+}
+
+// MajorVersion returns a version that only knows its major release
+func MajorVersion(major int) Version {
+	return Version{Major: major, MajorOnly: true}
+}
+
+// IsZero reports whether v is unset, i.e. no version was detected
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// AtLeast reports whether v is major.minor or later. A major-only version
+// counts as the first release of its major.
+func (v Version) AtLeast(major, minor int) bool {
+	return v.Compare(Version{Major: major, Minor: minor}) >= 0
+}
+
+// Compare returns -1, 0 or +1 as v is older than, the same as or newer
+// than other, ignoring MajorOnly
+func (v Version) Compare(other Version) int {
+	return cmp.Or(
+		cmp.Compare(v.Major, other.Major),
+		cmp.Compare(v.Minor, other.Minor),
+		cmp.Compare(v.Patch, other.Patch),
+	)
+}
+
+func (v Version) String() string {
+	if v.MajorOnly {
+		return fmt.Sprintf("%d.x", v.Major)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
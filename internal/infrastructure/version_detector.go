@@ -1,10 +1,6 @@
 package infrastructure
 
-import (
-	"context"
-	"regexp"
-	"strings"
-)
+import "context"
 
 // VersionDetector detects Puma version from stats
 type VersionDetector struct {
@@ -18,80 +14,82 @@ func NewVersionDetector(client PumaClient) *VersionDetector {
 	}
 }
 
-// DetectVersion attempts to detect the Puma version
-func (d *VersionDetector) DetectVersion(ctx context.Context) (string, error) {
-	// Try to get stats first
+// DetectVersion fetches /stats and detects the Puma version from it (see
+// DetectStatsVersion)
+func (d *VersionDetector) DetectVersion(ctx context.Context) (Version, error) {
 	stats, err := d.client.GetStats(ctx)
 	if err != nil {
-		return "", err
+		return Version{}, err
 	}
 
-	// Check for version-specific fields
-	if stats.RequestsCount != nil {
-		// Puma 6.x has requests_count field
-		return "6.x", nil
+	version, _ := DetectStatsVersion(stats)
+	return version, nil
+}
+
+// DetectStatsVersion returns the version in the versions block of stats,
+// reported by recent Puma releases, and otherwise guesses the major release
+// from the fields present. fromVersions reports whether the versions block
+// was used.
+func DetectStatsVersion(stats *PumaStats) (version Version, fromVersions bool) {
+	if stats.Versions != nil && stats.Versions.Puma != "" {
+		if version, err := ParseVersion(stats.Versions.Puma); err == nil {
+			return version, true
+		}
+	}
+	return guessVersion(stats), false
+}
+
+// guessVersion guesses the major release from the fields in stats. Until
+// cluster workers report their first status, only the top-level fields are
+// there to go by, so the guess may be an older release than Puma is.
+func guessVersion(stats *PumaStats) Version {
+	// Puma 7 reports backlog_max and reactor_max
+	if stats.BacklogMax != nil || stats.ReactorMax != nil {
+		return MajorVersion(7)
+	}
+	for _, worker := range stats.WorkerStatus {
+		if worker.LastStatus.BacklogMax != nil || worker.LastStatus.ReactorMax != nil {
+			return MajorVersion(7)
+		}
 	}
 
-	if len(stats.WorkerStatus) > 0 {
-		// Check worker status for more detailed version info
-		for _, worker := range stats.WorkerStatus {
-			// Puma 5.x has more detailed worker status
-			if worker.LastStatus.MaxThreads > 0 {
-				return "5.x", nil
-			}
+	// Puma 6.6 added busy_threads
+	if stats.BusyThreads != nil {
+		return MajorVersion(6)
+	}
+	for _, worker := range stats.WorkerStatus {
+		if worker.LastStatus.BusyThreads != nil {
+			return MajorVersion(6)
+		}
+	}
+
+	// Puma 5 added started_at and requests_count; Puma 4 already reported
+	// pool_capacity and max_threads
+	if stats.StartedAt != "" || stats.RequestsCount != nil {
+		return MajorVersion(5)
+	}
+	for _, worker := range stats.WorkerStatus {
+		if worker.StartedAt != "" || worker.LastStatus.RequestsCount != nil {
+			return MajorVersion(5)
 		}
 	}
 
 	// Default to 4.x for older versions
-	return "4.x", nil
+	return MajorVersion(4)
 }
 
 // GetVersionFromGCStats tries to extract version from gc-stats endpoint
-func (d *VersionDetector) GetVersionFromGCStats(ctx context.Context) (string, error) {
+func (d *VersionDetector) GetVersionFromGCStats(ctx context.Context) (Version, error) {
 	gcStats, err := d.client.GetGCStats(ctx)
 	if err != nil {
 		// GC stats might not be available in newer versions
-		return "", err
+		return Version{}, err
 	}
 
 	// Look for version string in GC stats
 	if version, ok := gcStats["version"].(string); ok {
-		return extractVersionNumber(version), nil
-	}
-
-	return "", nil
-}
-
-// extractVersionNumber extracts the version number from a version string
-func extractVersionNumber(versionStr string) string {
-	// Match patterns like "5.0.0", "6.6.1"
-	re := regexp.MustCompile(`(\d+\.\d+\.\d+)`)
-	matches := re.FindStringSubmatch(versionStr)
-	if len(matches) > 1 {
-		return matches[1]
+		return ParseVersion(version)
 	}
 
-	// Try to match major.minor
-	re = regexp.MustCompile(`(\d+\.\d+)`)
-	matches = re.FindStringSubmatch(versionStr)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-
-	// Try to match major version only
-	re = regexp.MustCompile(`(\d+)`)
-	matches = re.FindStringSubmatch(versionStr)
-	if len(matches) > 1 {
-		return matches[1] + ".x"
-	}
-
-	return versionStr
-}
-
-// IsPuma6OrLater checks if the detected version is Puma 6.x or later
-func IsPuma6OrLater(version string) bool {
-	return strings.HasPrefix(version, "6.") ||
-		strings.HasPrefix(version, "7.") ||
-		strings.HasPrefix(version, "8.") ||
-		strings.HasPrefix(version, "9.")
+	return Version{}, nil
 }
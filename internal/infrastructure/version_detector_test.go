@@ -9,9 +9,10 @@ import (
 
 // MockPumaClient is a mock implementation of PumaClient
 type MockPumaClient struct {
-	stats   *infrastructure.PumaStats
-	gcStats map[string]interface{}
-	err     error
+	stats      *infrastructure.PumaStats
+	gcStats    map[string]interface{}
+	backtraces []infrastructure.ThreadBacktrace
+	err        error
 }
 
 func (m *MockPumaClient) GetStats(ctx context.Context) (*infrastructure.PumaStats, error) {
@@ -28,6 +29,13 @@ func (m *MockPumaClient) GetGCStats(ctx context.Context) (map[string]interface{}
 	return m.gcStats, nil
 }
 
+func (m *MockPumaClient) GetThreadBacktraces(ctx context.Context) ([]infrastructure.ThreadBacktrace, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.backtraces, nil
+}
+
 func TestVersionDetector_DetectVersion(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -36,22 +44,46 @@ func TestVersionDetector_DetectVersion(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "Puma 6.x with requests_count",
+			name: "Puma 7 cluster with backlog_max",
+			stats: &infrastructure.PumaStats{
+				Workers:       2,
+				RequestsCount: int64Ptr(10),
+				WorkerStatus: []infrastructure.WorkerStatus{
+					{PID: 1234, LastStatus: infrastructure.LastStatus{MaxThreads: 3, BusyThreads: intPtr(1), BacklogMax: intPtr(0)}},
+				},
+			},
+			want:    "7.x",
+			wantErr: false,
+		},
+		{
+			name: "Puma 5.x with requests_count",
 			stats: &infrastructure.PumaStats{
 				Workers:       4,
 				RequestsCount: int64Ptr(12345),
 			},
+			want:    "5.x",
+			wantErr: false,
+		},
+		{
+			name: "Puma 6.6 cluster with busy_threads",
+			stats: &infrastructure.PumaStats{
+				Workers: 2,
+				WorkerStatus: []infrastructure.WorkerStatus{
+					{PID: 1234, LastStatus: infrastructure.LastStatus{MaxThreads: 5, BusyThreads: intPtr(2)}},
+				},
+			},
 			want:    "6.x",
 			wantErr: false,
 		},
 		{
-			name: "Puma 5.x with detailed worker status",
+			name: "Puma 5.x with worker started_at",
 			stats: &infrastructure.PumaStats{
 				Workers: 4,
 				WorkerStatus: []infrastructure.WorkerStatus{
 					{
-						PID:   1234,
-						Index: 0,
+						PID:       1234,
+						Index:     0,
+						StartedAt: "2025-01-01T00:00:00Z",
 						LastStatus: infrastructure.LastStatus{
 							MaxThreads: 16,
 						},
@@ -61,6 +93,34 @@ func TestVersionDetector_DetectVersion(t *testing.T) {
 			want:    "5.x",
 			wantErr: false,
 		},
+		{
+			name: "Puma 5.x single mode",
+			stats: &infrastructure.PumaStats{
+				StartedAt:  "2025-01-01T00:00:00Z",
+				Backlog:    intPtr(0),
+				MaxThreads: intPtr(5),
+			},
+			want:    "5.x",
+			wantErr: false,
+		},
+		{
+			name: "Puma 4.x single mode with max_threads",
+			stats: &infrastructure.PumaStats{
+				Backlog:    intPtr(0),
+				MaxThreads: intPtr(5),
+			},
+			want:    "4.x",
+			wantErr: false,
+		},
+		{
+			name: "versions block wins over heuristics",
+			stats: &infrastructure.PumaStats{
+				RequestsCount: int64Ptr(10),
+				Versions:      &infrastructure.Versions{Puma: "7.0.4"},
+			},
+			want:    "7.0.4",
+			wantErr: false,
+		},
 		{
 			name: "Puma 4.x default",
 			stats: &infrastructure.PumaStats{
@@ -83,37 +143,17 @@ func TestVersionDetector_DetectVersion(t *testing.T) {
 				t.Errorf("DetectVersion() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if got != tt.want {
+			if got.String() != tt.want {
 				t.Errorf("DetectVersion() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestIsPuma6OrLater(t *testing.T) {
-	tests := []struct {
-		version string
-		want    bool
-	}{
-		{"6.x", true},
-		{"6.0.0", true},
-		{"6.6.1", true},
-		{"7.0.0", true},
-		{"5.x", false},
-		{"5.6.1", false},
-		{"4.x", false},
-		{"", false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.version, func(t *testing.T) {
-			if got := infrastructure.IsPuma6OrLater(tt.version); got != tt.want {
-				t.Errorf("IsPuma6OrLater(%s) = %v, want %v", tt.version, got, tt.want)
-			}
-		})
-	}
+func int64Ptr(i int64) *int64 {
+	return &i
 }
 
-func int64Ptr(i int64) *int64 {
+func intPtr(i int) *int {
 	return &i
 }
@@ -0,0 +1,76 @@
+package infrastructure_test
+
+import (
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/infrastructure"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    infrastructure.Version
+		wantErr bool
+	}{
+		{"6.6.1", infrastructure.Version{Major: 6, Minor: 6, Patch: 1}, false},
+		{"6.6", infrastructure.Version{Major: 6, Minor: 6}, false},
+		{"6.x", infrastructure.MajorVersion(6), false},
+		{"7", infrastructure.MajorVersion(7), false},
+		{"10.0.0", infrastructure.Version{Major: 10}, false},
+		{"v7.0.0.pre1", infrastructure.Version{Major: 7}, false},
+		{"puma 6.4.2 (ruby 3.3.0-p0) (Birdie's Version)", infrastructure.Version{Major: 6, Minor: 4, Patch: 2}, false},
+		{"", infrastructure.Version{}, true},
+		{"unknown", infrastructure.Version{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := infrastructure.ParseVersion(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion_AtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		major   int
+		minor   int
+		want    bool
+	}{
+		{"6.x", 6, 0, true},
+		{"6.x", 6, 6, false},
+		{"6.6.1", 6, 6, true},
+		{"7.0.0", 6, 0, true},
+		{"10.0.0", 7, 0, true},
+		{"10.0.0", 11, 0, false},
+		{"5.6.1", 6, 0, false},
+		{"4.x", 5, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			version, err := infrastructure.ParseVersion(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := version.AtLeast(tt.major, tt.minor); got != tt.want {
+				t.Errorf("%s.AtLeast(%d, %d) = %v, want %v", tt.version, tt.major, tt.minor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion_String(t *testing.T) {
+	if got := infrastructure.MajorVersion(7).String(); got != "7.x" {
+		t.Errorf("String() = %q, want 7.x", got)
+	}
+	if got := (infrastructure.Version{Major: 6, Minor: 6, Patch: 1}).String(); got != "6.6.1" {
+		t.Errorf("String() = %q, want 6.6.1", got)
+	}
+}
@@ -0,0 +1,63 @@
+package presentation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// maxThreadNames bounds the thread names listed for a group
+const maxThreadNames = 5
+
+// FormatBacktraceSummary formats a backtrace summary for reading during an
+// incident: the thread count per category, then each group of threads with
+// the top frames they share
+func FormatBacktraceSummary(summary *domain.BacktraceSummary) string {
+	var b strings.Builder
+
+	var counts []string
+	for _, category := range domain.ThreadCategories {
+		if count := summary.Counts[category]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%s %d", category, count))
+		}
+	}
+	b.WriteString(threadCount(summary.Threads))
+	if len(counts) > 0 {
+		fmt.Fprintf(&b, ": %s", strings.Join(counts, ", "))
+	}
+	b.WriteString("\n")
+
+	for _, group := range summary.Groups {
+		fmt.Fprintf(&b, "\n[%s] %s\n", group.Category, threadCount(len(group.Threads)))
+		fmt.Fprintf(&b, "  %s\n", threadNames(group.Threads))
+		for _, frame := range group.Frames {
+			fmt.Fprintf(&b, "    %s\n", frame)
+		}
+		if len(group.Frames) == 0 {
+			b.WriteString("    (no backtrace)\n")
+		}
+	}
+
+	return b.String()
+}
+
+func threadCount(n int) string {
+	if n == 1 {
+		return "1 thread"
+	}
+	return fmt.Sprintf("%d threads", n)
+}
+
+// threadNames lists the first names, without Puma's "Thread: " prefix
+func threadNames(names []string) string {
+	shown := make([]string, 0, min(len(names), maxThreadNames))
+	for _, name := range names[:min(len(names), maxThreadNames)] {
+		shown = append(shown, strings.TrimPrefix(name, "Thread: "))
+	}
+	list := strings.Join(shown, ", ")
+	if more := len(names) - len(shown); more > 0 {
+		list += fmt.Sprintf(" and %d more", more)
+	}
+	return list
+}
@@ -0,0 +1,18 @@
+package presentation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// FormatCheckResult formats a check result as the one-line output expected
+// from a mackerel check plugin
+func FormatCheckResult(result domain.CheckResult) string {
+	message := result.Summary
+	if len(result.Problems) > 0 {
+		message = strings.Join(result.Problems, "; ")
+	}
+	return fmt.Sprintf("Puma %s: %s", result.Status, message)
+}
@@ -1,27 +1,183 @@
 package presentation
 
 import (
-	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"maps"
+	"slices"
+	"strings"
+
 	mp "github.com/mackerelio/go-mackerel-plugin"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
 )
 
+// GraphOptions controls which optional graphs are defined
+type GraphOptions struct {
+	// PerWorker adds wildcard graphs for workers.<index>.* metrics
+	PerWorker bool
+	// LabelPrefix starts every graph title; DefaultLabelPrefix is used when empty
+	LabelPrefix string
+	// MultiInstance namespaces every graph by instance name, e.g.
+	// puma.<instance>.workers.workers
+	MultiInstance bool
+	// Raw adds wildcard graphs for the raw.stats.* and raw.workers.*
+	// passthrough metrics
+	Raw bool
+	// SingleMode leaves out the graphs of worker metrics, which Puma only
+	// reports in clustered mode
+	SingleMode bool
+}
+
+// workerGraphs are the graphs left out in single mode
+var workerGraphs = []string{"workers", "worker_checkin", "worker_churn", "worker_age", "phase", "workers.#", "raw.workers"}
+
+// DefaultLabelPrefix derives a graph title prefix from a metric key prefix,
+// e.g. puma -> Puma, app1_puma -> App1_puma
+func DefaultLabelPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	return strings.ToUpper(prefix[:1]) + prefix[1:]
+}
+
 // MackerelPlugin implements the Mackerel plugin interface
 type MackerelPlugin struct {
-	prefix string
+	prefix  string
+	options GraphOptions
+	// graphKeys maps a metric name to the key of its graph, for building
+	// wildcard metric keys in multi-instance mode
+	graphKeys map[string]string
 }
 
 // NewMackerelPlugin creates a new Mackerel plugin
-func NewMackerelPlugin(prefix string) *MackerelPlugin {
-	return &MackerelPlugin{
-		prefix: prefix,
+func NewMackerelPlugin(prefix string, options GraphOptions) *MackerelPlugin {
+	if options.LabelPrefix == "" {
+		options.LabelPrefix = DefaultLabelPrefix(prefix)
+	}
+	p := &MackerelPlugin{
+		prefix:    prefix,
+		options:   options,
+		graphKeys: make(map[string]string),
+	}
+	for key, graph := range p.graphs() {
+		if strings.Contains(key, "#") {
+			continue
+		}
+		for _, metric := range graph.Metrics {
+			p.graphKeys[metric.Name] = key
+		}
 	}
+	return p
 }
 
-// GraphDefinition returns graph definitions for Mackerel
+// MetricKeyPrefix returns the prefix go-mackerel-plugin puts in front of
+// every graph name and metric key, e.g. puma.workers.workers
+func (p *MackerelPlugin) MetricKeyPrefix() string {
+	return p.prefix
+}
+
+// GraphDefinition returns graph definitions for Mackerel, titled with the
+// label prefix so that several plugin instances can be told apart
 func (p *MackerelPlugin) GraphDefinition() map[string]mp.Graphs {
+	graphs := make(map[string]mp.Graphs)
+	for key, graph := range p.graphs() {
+		graph.Label = p.options.LabelPrefix + " " + graph.Label
+		if p.options.MultiInstance {
+			// # matches the instance name
+			key = "#." + key
+		}
+		graphs[key] = graph
+	}
+	return graphs
+}
+
+// graphs returns the graphs enabled by the options, before labelling
+func (p *MackerelPlugin) graphs() map[string]mp.Graphs {
+	graphs := p.baseGraphs()
+	if p.options.PerWorker {
+		maps.Copy(graphs, perWorkerGraphs())
+	}
+	if p.options.Raw {
+		maps.Copy(graphs, rawGraphs())
+	}
+	if p.options.SingleMode {
+		for _, key := range workerGraphs {
+			delete(graphs, key)
+		}
+	}
+	if p.options.MultiInstance {
+		graphs["up"] = mp.Graphs{
+			Label: "Up",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "up", Label: "Up"},
+			},
+		}
+		// As a wildcard ruby.gc.old_objects would also match
+		// ruby.gc.old_objects_limit, so the limit gets a graph of its own
+		oldObjects := graphs["ruby_old_objects"]
+		oldObjects.Metrics = slices.DeleteFunc(oldObjects.Metrics, func(metric mp.Metrics) bool {
+			return metric.Name == "ruby.gc.old_objects_limit"
+		})
+		graphs["ruby_old_objects"] = oldObjects
+		graphs["ruby_old_objects_limit"] = mp.Graphs{
+			Label: "Ruby Old Objects Limit",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "ruby.gc.old_objects_limit", Label: "Old Objects Limit"},
+			},
+		}
+	}
+	return graphs
+}
+
+// perWorkerGraphs returns wildcard graphs for per-worker metrics; # matches
+// the worker index. go-mackerel-plugin matches wildcard keys by prefix, so
+// no metric name here may start with another (backlog, peak_backlog).
+func perWorkerGraphs() map[string]mp.Graphs {
+	return map[string]mp.Graphs{
+		"workers.#": {
+			Label: "Per-Worker Threads",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "backlog", Label: "Backlog"},
+				{Name: "running", Label: "Running"},
+				{Name: "busy_threads", Label: "Busy"},
+				{Name: "peak_backlog", Label: "Backlog (peak)"},
+				{Name: "peak_reactor", Label: "Reactor Queue (peak)"},
+				{Name: "pool_capacity", Label: "Pool Capacity"},
+				{Name: "max_threads", Label: "Max Threads"},
+				{Name: "requests_count", Label: "Requests", Diff: true},
+				{Name: "checkin_age", Label: "Checkin Age (sec)"},
+			},
+		},
+	}
+}
+
+// rawGraphs returns wildcard graphs for the raw passthrough metrics; #
+// matches the stats field name
+func rawGraphs() map[string]mp.Graphs {
+	return map[string]mp.Graphs{
+		"raw.stats": {
+			Label: "Raw Stats",
+			Unit:  mp.UnitFloat,
+			Metrics: []mp.Metrics{
+				{Name: "#", Label: "%1"},
+			},
+		},
+		"raw.workers": {
+			Label: "Raw Worker Stats (sum)",
+			Unit:  mp.UnitFloat,
+			Metrics: []mp.Metrics{
+				{Name: "#", Label: "%1"},
+			},
+		},
+	}
+}
+
+// baseGraphs returns the graphs that are always defined
+func (p *MackerelPlugin) baseGraphs() map[string]mp.Graphs {
 	return map[string]mp.Graphs{
 		"workers": {
-			Label: "Puma Workers",
+			Label: "Workers",
 			Unit:  mp.UnitInteger,
 			Metrics: []mp.Metrics{
 				{Name: "workers", Label: "Workers"},
@@ -30,56 +186,165 @@ func (p *MackerelPlugin) GraphDefinition() map[string]mp.Graphs {
 			},
 		},
 		"threads": {
-			Label: "Puma Threads",
+			Label: "Threads",
 			Unit:  mp.UnitInteger,
 			Metrics: []mp.Metrics{
 				{Name: "running", Label: "Running"},
+				{Name: "busy_threads", Label: "Busy"},
 				{Name: "pool_capacity", Label: "Pool Capacity"},
 				{Name: "max_threads", Label: "Max Threads"},
+				{Name: "sampled.running_max", Label: "Running (peak)"},
 			},
 		},
 		"backlog": {
-			Label: "Puma Backlog",
-			Unit:  mp.UnitInteger,
+			Label: "Backlog",
+			Unit:  mp.UnitFloat,
 			Metrics: []mp.Metrics{
 				{Name: "backlog", Label: "Backlog"},
+				{Name: "sampled.backlog_max", Label: "Backlog (peak)"},
+				{Name: "sampled.backlog_avg", Label: "Backlog (average)"},
+				{Name: "peak_backlog", Label: "Backlog (Puma peak)"},
+				{Name: "peak_reactor", Label: "Reactor Queue (Puma peak)"},
+			},
+		},
+		"worker_checkin": {
+			Label: "Worker Checkin",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "workers.max_checkin_age", Label: "Max Checkin Age (sec)"},
+				{Name: "workers.stale", Label: "Stale Workers"},
+			},
+		},
+		"worker_churn": {
+			Label: "Worker Churn",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "workers.restarts", Label: "Restarts"},
+				{Name: "workers.phase_changes", Label: "Phase Changes"},
+			},
+		},
+		"worker_age": {
+			Label: "Worker Age",
+			Unit:  mp.UnitSeconds,
+			Metrics: []mp.Metrics{
+				{Name: "workers.min_age", Label: "Youngest Worker"},
 			},
 		},
 		"phase": {
-			Label: "Puma Phase",
+			Label: "Phase",
 			Unit:  mp.UnitInteger,
 			Metrics: []mp.Metrics{
 				{Name: "phase", Label: "Phase"},
 			},
 		},
+		"mode": {
+			Label: "Mode",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "mode", Label: "Cluster (1) / Single (0)"},
+			},
+		},
+		"thread_activity": {
+			Label: "Thread Activity",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "thread_activity.idle", Label: "Idle"},
+				{Name: "thread_activity.database", Label: "Database"},
+				{Name: "thread_activity.http", Label: "HTTP"},
+				{Name: "thread_activity.puma", Label: "Puma"},
+				{Name: "thread_activity.ruby", Label: "Ruby"},
+				{Name: "thread_activity.unknown", Label: "Unknown"},
+			},
+		},
 		"requests": {
-			Label: "Puma Requests",
+			Label: "Requests",
 			Unit:  mp.UnitInteger,
 			Metrics: []mp.Metrics{
 				{Name: "requests_count", Label: "Requests Count", Diff: true},
 			},
 		},
 		"uptime": {
-			Label: "Puma Uptime",
+			Label: "Uptime",
 			Unit:  mp.UnitInteger,
 			Metrics: []mp.Metrics{
 				{Name: "uptime", Label: "Uptime"},
 			},
 		},
 		"memory": {
-			Label: "Memory Usage",
+			Label: "Memory",
+			Unit:  mp.UnitBytes,
+			Metrics: []mp.Metrics{
+				{Name: "memory.rss", Label: "RSS"},
+				{Name: "memory.pss", Label: "PSS"},
+				{Name: "memory.uss", Label: "USS"},
+				{Name: "memory.swap", Label: "Swap"},
+			},
+		},
+		"process_memory.#": {
+			Label: "Process Memory",
+			Unit:  mp.UnitBytes,
+			Metrics: []mp.Metrics{
+				{Name: "rss", Label: "RSS"},
+				{Name: "pss", Label: "PSS"},
+				{Name: "uss", Label: "USS"},
+				{Name: "swap", Label: "Swap"},
+			},
+		},
+		"cpu": {
+			Label: "CPU",
+			Unit:  mp.UnitPercentage,
+			Metrics: []mp.Metrics{
+				{Name: "cpu.user", Label: "User"},
+				{Name: "cpu.system", Label: "System"},
+				{Name: "cpu.usage", Label: "Total"},
+			},
+		},
+		"process_cpu.#": {
+			Label: "Process CPU",
+			Unit:  mp.UnitPercentage,
+			Metrics: []mp.Metrics{
+				{Name: "usage", Label: "Usage"},
+			},
+		},
+		"footprint_per_cpu": {
+			Label: "Footprint per CPU",
+			Unit:  mp.UnitFloat,
+			Metrics: []mp.Metrics{
+				{Name: "footprint.max_threads_per_cpu", Label: "Max Threads"},
+				{Name: "footprint.workers_per_cpu", Label: "Workers"},
+				{Name: "footprint.load_per_cpu", Label: "Host Load Average"},
+			},
+		},
+		"footprint_share": {
+			Label: "Footprint Share of Host",
+			Unit:  mp.UnitPercentage,
+			Metrics: []mp.Metrics{
+				{Name: "footprint.memory_percent", Label: "RSS % of Memory"},
+				{Name: "footprint.cpu_percent", Label: "CPU % of Host"},
+				{Name: "footprint.memory_available_percent", Label: "Host Memory Available"},
+			},
+		},
+		"plugin_memory": {
+			Label: "Plugin Memory Usage",
 			Unit:  mp.UnitFloat,
 			Metrics: []mp.Metrics{
-				{Name: "memory.alloc", Label: "Allocated"},
-				{Name: "memory.sys", Label: "System"},
-				{Name: "memory.heap_inuse", Label: "Heap In Use"},
+				{Name: "plugin.memory.alloc", Label: "Allocated"},
+				{Name: "plugin.memory.sys", Label: "System"},
+				{Name: "plugin.memory.heap_inuse", Label: "Heap In Use"},
+			},
+		},
+		"plugin_runtime": {
+			Label: "Plugin Runtime",
+			Unit:  mp.UnitInteger,
+			Metrics: []mp.Metrics{
+				{Name: "plugin.gc.num_gc", Label: "GC Count", Diff: true},
+				{Name: "plugin.goroutines", Label: "Goroutines"},
 			},
 		},
 		"gc": {
 			Label: "Garbage Collection",
 			Unit:  mp.UnitInteger,
 			Metrics: []mp.Metrics{
-				{Name: "gc.num_gc", Label: "GC Count", Diff: true},
 				{Name: "ruby.gc.count", Label: "Ruby GC Count", Diff: true},
 			},
 		},
@@ -104,8 +369,8 @@ func (p *MackerelPlugin) GraphDefinition() map[string]mp.Graphs {
 			Unit:  mp.UnitInteger,
 			Metrics: []mp.Metrics{
 				{Name: "ruby.gc.heap_available_slots", Label: "Available Slots"},
-				{Name: "ruby.gc.heap_live_slots", Label: "Live Slots", Stacked: true},
-				{Name: "ruby.gc.heap_free_slots", Label: "Free Slots", Stacked: true},
+				{Name: "ruby.gc.heap_live_slots", Label: "Live Slots"},
+				{Name: "ruby.gc.heap_free_slots", Label: "Free Slots"},
 				{Name: "ruby.gc.heap_final_slots", Label: "Final Slots"},
 				{Name: "ruby.gc.heap_marked_slots", Label: "Marked Slots"},
 			},
@@ -131,6 +396,7 @@ func (p *MackerelPlugin) GraphDefinition() map[string]mp.Graphs {
 			Unit:  mp.UnitPercentage,
 			Metrics: []mp.Metrics{
 				{Name: "thread_utilization", Label: "Utilization %"},
+				{Name: "sampled.utilization_p95", Label: "Utilization % (p95)"},
 			},
 		},
 	}
@@ -141,14 +407,32 @@ func (p *MackerelPlugin) FormatMetrics(collection *domain.MetricCollection) map[
 	result := make(map[string]float64)
 
 	for _, metric := range collection.All() {
-		key := p.buildMetricKey(metric.Name)
+		key := p.buildMetricKey(metric)
 		result[key] = metric.Value
 	}
 
 	return result
 }
 
-// buildMetricKey builds the full metric key with prefix
-func (p *MackerelPlugin) buildMetricKey(name string) string {
-	return name
-}
\ No newline at end of file
+// buildMetricKey builds the metric key; go-mackerel-plugin adds the
+// MetricKeyPrefix and graph name when printing values
+func (p *MackerelPlugin) buildMetricKey(metric domain.Metric) string {
+	key := metric.Name
+	// workers.backlog for worker 0 becomes workers.0.backlog
+	if worker, ok := metric.Labels[domain.LabelWorker]; ok {
+		if group, field, found := strings.Cut(metric.Name, "."); found {
+			key = group + "." + worker + "." + field
+		}
+	}
+
+	instance, ok := metric.Labels[domain.LabelInstance]
+	if !ok {
+		return key
+	}
+	// Wildcard graphs only match keys that spell out the graph name, so
+	// backlog of instance app1 becomes app1.backlog.backlog
+	if graphKey, found := p.graphKeys[metric.Name]; found {
+		return instance + "." + graphKey + "." + key
+	}
+	return instance + "." + key
+}
@@ -0,0 +1,283 @@
+package presentation_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	mp "github.com/mackerelio/go-mackerel-plugin"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
+)
+
+func TestMackerelPlugin_FormatMetrics(t *testing.T) {
+	plugin := presentation.NewMackerelPlugin("puma", presentation.GraphOptions{PerWorker: true})
+
+	collection := domain.NewMetricCollection()
+	_ = collection.Add(domain.Metric{Name: "backlog", Value: 3, Type: domain.MetricTypeGauge})
+	_ = collection.Add(domain.Metric{
+		Name:   "workers.backlog",
+		Value:  2,
+		Type:   domain.MetricTypeGauge,
+		Labels: map[string]string{domain.LabelWorker: "1", domain.LabelPID: "1234"},
+	})
+
+	got := plugin.FormatMetrics(collection)
+
+	if got["backlog"] != 3 {
+		t.Errorf("backlog = %v, want 3", got["backlog"])
+	}
+	if got["workers.1.backlog"] != 2 {
+		t.Errorf("workers.1.backlog = %v, want 2", got["workers.1.backlog"])
+	}
+	if _, ok := plugin.GraphDefinition()["workers.#"]; !ok {
+		t.Error("GraphDefinition() should include workers.# when PerWorker is set")
+	}
+}
+
+func TestMackerelPlugin_GraphDefinition(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		options presentation.GraphOptions
+		want    string
+	}{
+		{name: "default prefix", prefix: "puma", want: "Puma Workers"},
+		{name: "derived from key prefix", prefix: "app1_puma", want: "App1_puma Workers"},
+		{name: "explicit label prefix", prefix: "app1_puma", options: presentation.GraphOptions{LabelPrefix: "App1"}, want: "App1 Workers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := presentation.NewMackerelPlugin(tt.prefix, tt.options)
+			if got := plugin.GraphDefinition()["workers"].Label; got != tt.want {
+				t.Errorf("workers graph label = %q, want %q", got, tt.want)
+			}
+			if got := plugin.MetricKeyPrefix(); got != tt.prefix {
+				t.Errorf("MetricKeyPrefix() = %q, want %q", got, tt.prefix)
+			}
+		})
+	}
+}
+
+// baselineKeys are the metric keys 2.0.0 printed with the default prefix,
+// which existing puma.* dashboards and alerts refer to
+var baselineKeys = []string{
+	"puma.workers.workers", "puma.workers.booted_workers", "puma.workers.old_workers",
+	"puma.threads.running", "puma.threads.pool_capacity", "puma.threads.max_threads",
+	"puma.backlog.backlog",
+	"puma.phase.phase",
+	"puma.requests.requests_count",
+	"puma.uptime.uptime",
+	"puma.memory.memory.alloc", "puma.memory.memory.sys", "puma.memory.memory.heap_inuse",
+	"puma.gc.gc.num_gc", "puma.gc.ruby.gc.count",
+	"puma.ruby_heap.ruby.gc.heap_used", "puma.ruby_heap.ruby.gc.heap_length",
+	"puma.ruby_gc_detailed.ruby.gc.minor_count", "puma.ruby_gc_detailed.ruby.gc.major_count",
+	"puma.ruby_heap_slots.ruby.gc.heap_available_slots", "puma.ruby_heap_slots.ruby.gc.heap_live_slots",
+	"puma.ruby_heap_slots.ruby.gc.heap_free_slots", "puma.ruby_heap_slots.ruby.gc.heap_final_slots",
+	"puma.ruby_heap_slots.ruby.gc.heap_marked_slots",
+	"puma.ruby_old_objects.ruby.gc.old_objects", "puma.ruby_old_objects.ruby.gc.old_objects_limit",
+	"puma.ruby_old_malloc.ruby.gc.oldmalloc_bytes", "puma.ruby_old_malloc.ruby.gc.oldmalloc_limit",
+	"puma.thread_utilization.thread_utilization",
+}
+
+// movedKeys are the baseline keys of the plugin's own Go runtime, which
+// moved to plugin_memory and plugin_runtime (see CHANGELOG.md)
+var movedKeys = map[string]string{
+	"puma.memory.memory.alloc":      "puma.plugin_memory.plugin.memory.alloc",
+	"puma.memory.memory.sys":        "puma.plugin_memory.plugin.memory.sys",
+	"puma.memory.memory.heap_inuse": "puma.plugin_memory.plugin.memory.heap_inuse",
+	"puma.gc.gc.num_gc":             "puma.plugin_runtime.plugin.gc.num_gc",
+}
+
+func TestMackerelPlugin_BaselineKeys(t *testing.T) {
+	// The keys go-mackerel-plugin prints for graphs without wildcards
+	keys := make(map[string]bool)
+	plugin := presentation.NewMackerelPlugin("puma", presentation.GraphOptions{})
+	for graphKey, graph := range plugin.GraphDefinition() {
+		for _, metric := range graph.Metrics {
+			keys[plugin.MetricKeyPrefix()+"."+graphKey+"."+metric.Name] = true
+		}
+	}
+
+	for _, key := range baselineKeys {
+		if moved, ok := movedKeys[key]; ok {
+			if keys[key] || !keys[moved] {
+				t.Errorf("%s should have moved to %s", key, moved)
+			}
+			continue
+		}
+		if !keys[key] {
+			t.Errorf("baseline key %s is no longer printed", key)
+		}
+	}
+}
+
+func TestMackerelPlugin_SingleMode(t *testing.T) {
+	graphs := presentation.NewMackerelPlugin("puma", presentation.GraphOptions{PerWorker: true, SingleMode: true}).GraphDefinition()
+
+	for _, key := range []string{"workers", "worker_checkin", "phase", "workers.#"} {
+		if _, ok := graphs[key]; ok {
+			t.Errorf("graph %s should be left out in single mode", key)
+		}
+	}
+	for _, key := range []string{"threads", "backlog", "mode"} {
+		if _, ok := graphs[key]; !ok {
+			t.Errorf("graph %s missing in single mode", key)
+		}
+	}
+}
+
+func TestMackerelPlugin_MultiInstance(t *testing.T) {
+	plugin := presentation.NewMackerelPlugin("puma", presentation.GraphOptions{PerWorker: true, MultiInstance: true})
+
+	collection := domain.NewMetricCollection()
+	for _, instance := range []string{"app1", "app2"} {
+		_ = collection.Add(domain.Metric{Name: "backlog", Value: 3, Type: domain.MetricTypeGauge, Labels: map[string]string{domain.LabelInstance: instance}})
+		_ = collection.Add(domain.Metric{Name: "workers.stale", Value: 0, Type: domain.MetricTypeGauge, Labels: map[string]string{domain.LabelInstance: instance}})
+		_ = collection.Add(domain.Metric{Name: "up", Value: 1, Type: domain.MetricTypeGauge, Labels: map[string]string{domain.LabelInstance: instance}})
+		_ = collection.Add(domain.Metric{
+			Name:   "workers.backlog",
+			Value:  2,
+			Type:   domain.MetricTypeGauge,
+			Labels: map[string]string{domain.LabelWorker: "0", domain.LabelInstance: instance},
+		})
+	}
+
+	got := plugin.FormatMetrics(collection)
+	graphs := plugin.GraphDefinition()
+
+	for _, key := range []string{
+		"app1.backlog.backlog",
+		"app2.worker_checkin.workers.stale",
+		"app1.up.up",
+		"app2.workers.0.backlog",
+	} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("FormatMetrics() missing %s, got %v", key, got)
+		}
+	}
+	for key := range got {
+		if !graphCovers(graphs, key) {
+			t.Errorf("no graph definition matches %s", key)
+		}
+	}
+	if _, ok := graphs["backlog"]; ok {
+		t.Error("GraphDefinition() should only define instance wildcard graphs")
+	}
+}
+
+// graphCovers reports whether go-mackerel-plugin would print key for one of
+// the wildcard graphs
+func graphCovers(graphs map[string]mp.Graphs, key string) bool {
+	for graphKey, graph := range graphs {
+		for _, metric := range graph.Metrics {
+			pattern := regexp.QuoteMeta(graphKey + "." + metric.Name)
+			pattern = strings.ReplaceAll(pattern, "#", `[-a-zA-Z0-9_]+`)
+			if regexp.MustCompile(`\A` + pattern + `\z`).MatchString(key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestMackerelPlugin_MetricInOneGraph(t *testing.T) {
+	// go-mackerel-plugin prints a metric once for every graph that lists
+	// it, and multi-instance keys pick one of those graphs
+	plugin := presentation.NewMackerelPlugin("puma", presentation.GraphOptions{PerWorker: true, Raw: true})
+
+	graphOf := make(map[string]string)
+	for key, graph := range plugin.GraphDefinition() {
+		if strings.Contains(key, "#") {
+			continue
+		}
+		for _, metric := range graph.Metrics {
+			if strings.Contains(metric.Name, "#") {
+				continue
+			}
+			if other, ok := graphOf[metric.Name]; ok {
+				t.Errorf("metric %s is in graphs %s and %s", metric.Name, other, key)
+			}
+			graphOf[metric.Name] = key
+		}
+	}
+}
+
+func TestMackerelPlugin_OutputPerWorker(t *testing.T) {
+	plugin := presentation.NewMackerelPlugin("puma", presentation.GraphOptions{PerWorker: true})
+
+	collection := domain.NewMetricCollection()
+	labels := map[string]string{domain.LabelWorker: "0", domain.LabelPID: "1234"}
+	for _, name := range []string{"workers.backlog", "workers.running", "workers.peak_backlog", "workers.peak_reactor"} {
+		_ = collection.Add(domain.Metric{Name: name, Value: 1, Type: domain.MetricTypeGauge, Labels: labels})
+	}
+
+	// One line per metric, none printed again by a metric whose name is a
+	// prefix of it
+	lines := outputLines(t, plugin, collection)
+	if len(lines) != 4 {
+		t.Errorf("printed %d lines, want 4:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+}
+
+func TestMackerelPlugin_NoWildcardPrefixes(t *testing.T) {
+	// Every graph is a wildcard graph in multi-instance mode, and
+	// go-mackerel-plugin doesn't anchor the end of the key it matches
+	plugin := presentation.NewMackerelPlugin("puma", presentation.GraphOptions{PerWorker: true, Raw: true, MultiInstance: true})
+
+	for key, graph := range plugin.GraphDefinition() {
+		for _, metric := range graph.Metrics {
+			for _, other := range graph.Metrics {
+				if other.Name != metric.Name && strings.HasPrefix(other.Name, metric.Name) {
+					t.Errorf("graph %s: %s also matches %s", key, metric.Name, other.Name)
+				}
+			}
+		}
+	}
+}
+
+// fetchPlugin serves a fixed collection to go-mackerel-plugin
+type fetchPlugin struct {
+	*presentation.MackerelPlugin
+	collection *domain.MetricCollection
+}
+
+func (p fetchPlugin) FetchMetrics() (map[string]float64, error) {
+	return p.FormatMetrics(p.collection), nil
+}
+
+// outputLines returns the lines go-mackerel-plugin prints for collection,
+// without their timestamps
+func outputLines(t *testing.T, plugin *presentation.MackerelPlugin, collection *domain.MetricCollection) []string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	helper := mp.NewMackerelPlugin(fetchPlugin{plugin, collection})
+	helper.Tempfile = filepath.Join(t.TempDir(), "tempfile")
+	helper.OutputValues()
+	_ = w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, value, _ := strings.Cut(line, "\t")
+		value, _, _ = strings.Cut(value, "\t")
+		lines = append(lines, key+"\t"+value)
+	}
+	return lines
+}
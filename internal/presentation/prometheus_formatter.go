@@ -0,0 +1,103 @@
+package presentation
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+)
+
+// PrometheusFormatter renders metrics in the Prometheus text exposition format
+type PrometheusFormatter struct {
+	prefix string
+}
+
+// NewPrometheusFormatter creates a new Prometheus formatter
+func NewPrometheusFormatter(prefix string) *PrometheusFormatter {
+	return &PrometheusFormatter{
+		prefix: prefix,
+	}
+}
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Write writes the collection to w, grouping samples into metric families
+func (f *PrometheusFormatter) Write(w io.Writer, collection *domain.MetricCollection) error {
+	families := make(map[string][]domain.Metric)
+	for _, metric := range collection.All() {
+		families[metric.Name] = append(families[metric.Name], metric)
+	}
+
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		metrics := families[name]
+		promName, promType := f.metricName(name, metrics[0].Type), "gauge"
+		if metrics[0].Type == domain.MetricTypeCounter {
+			promType = "counter"
+		}
+
+		help := name
+		if def, ok := domain.MetricDefinitions[name]; ok {
+			help = def.Label
+		}
+
+		fmt.Fprintf(&b, "# HELP %s %s\n", promName, escapeHelp(help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", promName, promType)
+		for _, metric := range metrics {
+			fmt.Fprintf(&b, "%s%s %s\n", promName, formatLabels(metric.Labels), strconv.FormatFloat(metric.Value, 'g', -1, 64))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// metricName converts a dotted metric name to a Prometheus metric name,
+// e.g. workers.backlog -> puma_workers_backlog
+func (f *PrometheusFormatter) metricName(name string, metricType domain.MetricType) string {
+	promName := sanitizeName(f.prefix + "_" + name)
+	if metricType == domain.MetricTypeCounter && !strings.HasSuffix(promName, "_total") {
+		promName += "_total"
+	}
+	return promName
+}
+
+// sanitizeName replaces characters not allowed in Prometheus names
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// formatLabels renders labels as {key="value",...} in sorted key order
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, sanitizeName(key), escapeLabelValue(labels[key])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeHelp escapes backslashes and newlines in HELP text
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// escapeLabelValue escapes backslashes, double quotes and newlines in label values
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
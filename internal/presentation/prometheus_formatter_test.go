@@ -0,0 +1,40 @@
+package presentation_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/domain"
+	"github.com/srockstyle/mackerel-plugin-puma-v2/internal/presentation"
+)
+
+func TestPrometheusFormatter_Write(t *testing.T) {
+	collection := domain.NewMetricCollection()
+	_ = collection.Add(domain.Metric{Name: "backlog", Value: 3, Type: domain.MetricTypeGauge})
+	_ = collection.Add(domain.Metric{Name: "requests_count", Value: 12345, Type: domain.MetricTypeCounter})
+	_ = collection.Add(domain.Metric{
+		Name:   "workers.running",
+		Value:  5,
+		Type:   domain.MetricTypeGauge,
+		Labels: map[string]string{domain.LabelWorker: "0", domain.LabelPID: "1234"},
+	})
+
+	var b strings.Builder
+	if err := presentation.NewPrometheusFormatter("puma").Write(&b, collection); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `# HELP puma_backlog Backlog
+# TYPE puma_backlog gauge
+puma_backlog 3
+# HELP puma_requests_count_total Requests Count
+# TYPE puma_requests_count_total counter
+puma_requests_count_total 12345
+# HELP puma_workers_running Worker Running Threads
+# TYPE puma_workers_running gauge
+puma_workers_running{pid="1234",worker="0"} 5
+`
+	if got := b.String(); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 	"time"
@@ -69,9 +70,29 @@ func TestPumaPlugin_Integration(t *testing.T) {
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Skip HTTP endpoint test for now as it requires socket implementation
+	// Test with TCP control server
 	t.Run("HTTP endpoint", func(t *testing.T) {
-		t.Skip("HTTP endpoint test requires socket implementation")
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config := application.DefaultConfig()
+		config.Scheme = u.Scheme
+		config.Host = u.Hostname()
+		config.Port = u.Port()
+
+		logger := log.New(os.Stderr, "[test] ", log.LstdFlags)
+		collector := application.NewMetricsCollector(config, logger)
+
+		collection, err := collector.Collect(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to collect metrics via TCP: %v", err)
+		}
+
+		if len(collection.All()) == 0 {
+			t.Error("No metrics collected via TCP")
+		}
 	})
 
 	// Test with Unix socket